@@ -1,11 +1,6 @@
 package cmd
 
 import (
-	"os"
-	"time"
-
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
 	"github.com/nicklasfrahm/k3se/pkg/ops"
@@ -24,13 +19,11 @@ by passing a path to the configuration file as a CLI
 argument.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		logger := log.Output(zerolog.ConsoleWriter{
-			Out:        os.Stderr,
-			TimeFormat: time.RFC3339,
-		})
+		logger := newLogger()
 
 		opts := []ops.Option{
 			ops.WithLogger(&logger),
+			ops.WithSecure(secure),
 		}
 
 		// Use manual override for config path if provided.
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nicklasfrahm/k3se/pkg/engine"
+	"github.com/nicklasfrahm/k3se/pkg/ops"
+)
+
+var execCommand string
+var execRole string
+
+var execCmd = &cobra.Command{
+	Use:   "exec [config]",
+	Short: "Run a command on the nodes",
+	Long: `Run a command on all nodes of the cluster and print
+the output of each node prefixed with its name. Use
+the --role flag to only target servers or agents and
+the --node flag to only target specific nodes.
+
+By default the command expects a "k3se.yml" config
+file in the current directory. You may override this
+by passing a path to the configuration file as a CLI
+argument.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newLogger()
+
+		opts := []ops.Option{
+			ops.WithLogger(&logger),
+			ops.WithSecure(secure),
+			ops.WithNodes(nodeNames...),
+			ops.WithCommand(execCommand),
+			ops.WithSelector(engine.Role(execRole)),
+		}
+
+		// Use manual override for config path if provided.
+		if len(args) == 1 {
+			opts = append(opts, ops.WithConfigPath(args[0]))
+		}
+
+		return ops.Exec(opts...)
+	},
+}
+
+func init() {
+	execCmd.Flags().StringVarP(&execCommand, "command", "c", "", "command to run on the nodes")
+	execCmd.Flags().StringVarP(&execRole, "role", "r", string(engine.RoleAny), "role of the nodes to run the command on")
+	execCmd.MarkFlagRequired("command")
+
+	execCmd.Flags().StringSliceVarP(&nodeNames, "node", "n", nil, "names of the nodes to target")
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nicklasfrahm/k3se/pkg/ops"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [config]",
+	Short: "Show pending changes",
+	Long: `Show the changes that would be performed on each
+node when running "k3se up" without applying them.
+
+By default the command expects a "k3se.yml" config
+file in the current directory. You may override this
+by passing a path to the configuration file as a CLI
+argument.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newLogger()
+
+		opts := []ops.Option{
+			ops.WithLogger(&logger),
+			ops.WithSecure(secure),
+			ops.WithNodes(nodeNames...),
+		}
+
+		// Use manual override for config path if provided.
+		if len(args) == 1 {
+			opts = append(opts, ops.WithConfigPath(args[0]))
+		}
+
+		plan, err := ops.Plan(opts...)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NODE\tHOST\tROLE\tCURRENT\tDESIRED\tACTIONS")
+		for _, step := range plan.Nodes {
+			actions := make([]string, len(step.Actions))
+			for i, action := range step.Actions {
+				actions[i] = string(action)
+			}
+			if len(actions) == 0 {
+				actions = append(actions, "none")
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", step.Name, step.Host, step.Role, orDash(step.CurrentVersion), step.DesiredVersion, strings.Join(actions, ","))
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if len(plan.Addons) == 0 {
+			return nil
+		}
+
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ADDON\tCHART\tREQUESTED\tDESIRED\tLATEST\tACTION")
+		for _, addon := range plan.Addons {
+			action := string(addon.Action())
+			if action == "" {
+				action = "none"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", addon.Name, addon.Chart, orDash(addon.RequestedVersion),
+				orDash(addon.DesiredVersion), orDash(addon.LatestVersion), action)
+		}
+
+		return w.Flush()
+	},
+}
+
+func init() {
+	planCmd.Flags().StringSliceVarP(&nodeNames, "node", "n", nil, "names of the nodes to target")
+	rootCmd.AddCommand(planCmd)
+}
@@ -2,12 +2,21 @@ package cmd
 
 import (
 	"os"
+	"time"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+
+	"github.com/nicklasfrahm/k3se/pkg/engine"
+	"github.com/nicklasfrahm/k3se/pkg/ops"
 )
 
 var version = "dev"
 var help bool
+var secure bool
+var nodeNames []string
+var chaosSpec string
 
 var rootCmd = &cobra.Command{
 	Use:   "k3se",
@@ -37,6 +46,39 @@ file.`,
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&help, "help", "h", false, "display help for command")
+	rootCmd.PersistentFlags().BoolVar(&secure, "secure", false, "enforce host key verification, public key authentication, token files and installer checksums")
+}
+
+// newLogger creates a human-friendly logger that writes to stderr.
+func newLogger() zerolog.Logger {
+	return log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stderr,
+		TimeFormat: time.RFC3339,
+	})
+}
+
+// chaosOptions parses the chaos specification into an operation option.
+// No option is returned if no chaos specification was provided.
+func chaosOptions() ([]ops.Option, error) {
+	if chaosSpec == "" {
+		return nil, nil
+	}
+
+	chaos, err := engine.ParseChaos(chaosSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ops.Option{ops.WithChaos(chaos)}, nil
+}
+
+// orDash returns a dash for empty values to keep tables readable.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
 }
 
 // Execute starts the invocation of the command line interface.
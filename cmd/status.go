@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nicklasfrahm/k3se/pkg/ops"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [config]",
+	Short: "Show the status of all nodes",
+	Long: `Show the installed k3s version and the state of the
+k3s service on each node of the cluster.
+
+By default the command expects a "k3se.yml" config
+file in the current directory. You may override this
+by passing a path to the configuration file as a CLI
+argument.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newLogger()
+
+		opts := []ops.Option{
+			ops.WithLogger(&logger),
+			ops.WithSecure(secure),
+			ops.WithNodes(nodeNames...),
+		}
+
+		// Use manual override for config path if provided.
+		if len(args) == 1 {
+			opts = append(opts, ops.WithConfigPath(args[0]))
+		}
+
+		clusterStatus, err := ops.Status(opts...)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NODE\tHOST\tROLE\tVERSION\tSTATE")
+		for _, status := range clusterStatus.Nodes {
+			version := status.Version
+			state := "inactive"
+
+			if !status.Installed {
+				version = "-"
+				state = "not installed"
+			} else if status.Active {
+				state = "active"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", status.Name, status.Host, status.Role, version, state)
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if len(clusterStatus.Addons) == 0 {
+			return nil
+		}
+
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ADDON\tCHART\tREQUESTED\tAPP VERSION\tLATEST")
+		for _, addon := range clusterStatus.Addons {
+			latest := orDash(addon.LatestVersion)
+			if addon.UpdateAvailable() {
+				latest += " (update available)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", addon.Name, addon.Chart, orDash(addon.RequestedVersion),
+				orDash(addon.RequestedAppVersion), latest)
+		}
+
+		return w.Flush()
+	},
+}
+
+func init() {
+	statusCmd.Flags().StringSliceVarP(&nodeNames, "node", "n", nil, "names of the nodes to target")
+	rootCmd.AddCommand(statusCmd)
+}
@@ -1,11 +1,6 @@
 package cmd
 
 import (
-	"os"
-	"time"
-
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
 	"github.com/nicklasfrahm/k3se/pkg/ops"
@@ -18,6 +13,8 @@ var upCmd = &cobra.Command{
 	Use:   "up [config]",
 	Short: "Deploy or upgrade cluster",
 	Long: `Deploy a new cluster or upgrade an existing one.
+If a previous run was interrupted, the phases that
+were already completed on a node will be skipped.
 
 By default the command expects a "k3se.yml" config
 file in the current directory. You may override this
@@ -31,14 +28,18 @@ merge the new context to the kubeconfig located at
 the new context to be written to.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		logger := log.Output(zerolog.ConsoleWriter{
-			Out:        os.Stderr,
-			TimeFormat: time.RFC3339,
-		})
+		logger := newLogger()
 
 		opts := []ops.Option{
 			ops.WithLogger(&logger),
+			ops.WithSecure(secure),
+		}
+
+		chaosOpts, err := chaosOptions()
+		if err != nil {
+			return err
 		}
+		opts = append(opts, chaosOpts...)
 
 		// Use manual override for config path if provided.
 		if len(args) == 1 {
@@ -63,6 +64,7 @@ the new context to be written to.`,
 func init() {
 	upCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "k", "~/.kube/config", "location to write the kubeconfig")
 	upCmd.Flags().BoolVarP(&skipInstall, "skip-install", "s", false, "only download the kubeconfig")
+	upCmd.Flags().StringVar(&chaosSpec, "chaos", "", "inject faults for testing, e.g. \"kube2=ssh-drop,kube3=installer-failure\"")
 
 	rootCmd.AddCommand(upCmd)
 }
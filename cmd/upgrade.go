@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nicklasfrahm/k3se/pkg/ops"
+)
+
+var upgradeVersion string
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [config]",
+	Short: "Upgrade cluster to a specific version",
+	Long: `Upgrade an existing cluster to the version specified
+via the --version flag, regardless of the version in
+the config. Servers are upgraded one after another,
+followed by the agents.
+
+By default the command expects a "k3se.yml" config
+file in the current directory. You may override this
+by passing a path to the configuration file as a CLI
+argument.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newLogger()
+
+		opts := []ops.Option{
+			ops.WithLogger(&logger),
+			ops.WithSecure(secure),
+			ops.WithVersion(upgradeVersion),
+		}
+
+		chaosOpts, err := chaosOptions()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, chaosOpts...)
+
+		// Use manual override for config path if provided.
+		if len(args) == 1 {
+			opts = append(opts, ops.WithConfigPath(args[0]))
+		}
+
+		return ops.Upgrade(opts...)
+	},
+}
+
+func init() {
+	upgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "k3s version or release channel to upgrade to")
+	upgradeCmd.MarkFlagRequired("version")
+
+	upgradeCmd.Flags().StringVar(&chaosSpec, "chaos", "", "inject faults for testing, e.g. \"kube2=ssh-drop,kube3=installer-failure\"")
+
+	rootCmd.AddCommand(upgradeCmd)
+}
@@ -0,0 +1,465 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nicklasfrahm/k3se/pkg/sshx"
+)
+
+const (
+	// DefaultDataDir is the default data directory of k3s.
+	DefaultDataDir = "/var/lib/rancher/k3s"
+)
+
+// Addon describes a Helm chart that is deployed via the HelmChart
+// custom resource of the k3s helm controller. For more information,
+// please refer to the k3s documentation:
+// https://docs.k3s.io/helm#using-the-helm-controller
+type Addon struct {
+	Name      string                 `yaml:"name"`
+	Namespace string                 `yaml:"namespace,omitempty"`
+	Repo      string                 `yaml:"repo"`
+	Chart     string                 `yaml:"chart"`
+	Version   string                 `yaml:"version,omitempty"`
+	Values    map[string]interface{} `yaml:"values,omitempty"`
+}
+
+// RequestedAddon is the state of an addon as recorded on the servers.
+// It describes the chart version that was requested from the helm
+// controller, which may not have finished deploying it yet.
+type RequestedAddon struct {
+	Chart      string `yaml:"chart"`
+	Version    string `yaml:"version,omitempty"`
+	AppVersion string `yaml:"app-version,omitempty"`
+}
+
+// AddonStatus compares the requested version of an addon with the
+// version in the configuration and the latest upstream version.
+type AddonStatus struct {
+	Name                string
+	Chart               string
+	Deployed            bool
+	Removed             bool
+	RequestedVersion    string
+	RequestedAppVersion string
+	DesiredVersion      string
+	LatestVersion       string
+	LatestAppVersion    string
+}
+
+// Action returns the action that is required to bring the addon up to
+// date or an empty action if the addon is up to date. If no version is
+// configured, the addon is expected to run the latest upstream version.
+func (s *AddonStatus) Action() Action {
+	if s.Removed {
+		return ActionRemove
+	}
+
+	if !s.Deployed {
+		return ActionInstall
+	}
+
+	desired := s.DesiredVersion
+	if desired == "" {
+		desired = s.LatestVersion
+	}
+	if desired != "" && desired != s.RequestedVersion {
+		return ActionUpgrade
+	}
+
+	return ""
+}
+
+// UpdateAvailable checks if a newer stable upstream version is available.
+func (s *AddonStatus) UpdateAvailable() bool {
+	if s.LatestVersion == "" || s.RequestedVersion == "" || isPrerelease(s.LatestVersion) {
+		return false
+	}
+
+	return compareVersions(s.LatestVersion, s.RequestedVersion) > 0
+}
+
+// helmChart is the HelmChart custom resource of the k3s helm controller.
+type helmChart struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Repo            string `yaml:"repo"`
+		Chart           string `yaml:"chart"`
+		Version         string `yaml:"version,omitempty"`
+		TargetNamespace string `yaml:"targetNamespace,omitempty"`
+		CreateNamespace bool   `yaml:"createNamespace,omitempty"`
+		ValuesContent   string `yaml:"valuesContent,omitempty"`
+	} `yaml:"spec"`
+}
+
+// chartVersion is a version of a chart in a Helm chart repository.
+type chartVersion struct {
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion"`
+}
+
+// chartIndex is the index of a Helm chart repository.
+type chartIndex struct {
+	Entries map[string][]chartVersion `yaml:"entries"`
+}
+
+// chartVersions fetches the index of the chart repository and
+// returns all versions of the chart that are available.
+func chartVersions(repo string, chart string) ([]chartVersion, error) {
+	resp, err := http.Get(strings.TrimSuffix(repo, "/") + "/index.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch chart index: %s", resp.Status)
+	}
+
+	indexBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(chartIndex)
+	if err := yaml.Unmarshal(indexBytes, index); err != nil {
+		return nil, err
+	}
+
+	versions, ok := index.Entries[chart]
+	if !ok {
+		return nil, errors.New("chart not found in repository: " + chart)
+	}
+
+	return versions, nil
+}
+
+// latestChartVersion returns the highest stable version of the chart.
+func latestChartVersion(versions []chartVersion) (chartVersion, error) {
+	var latest chartVersion
+	for _, version := range versions {
+		if isPrerelease(version.Version) {
+			continue
+		}
+
+		if latest.Version == "" || compareVersions(version.Version, latest.Version) > 0 {
+			latest = version
+		}
+	}
+
+	if latest.Version == "" {
+		return latest, errors.New("no stable chart version found")
+	}
+
+	return latest, nil
+}
+
+// LatestChartVersion fetches the index of the chart repository and
+// returns the latest stable chart version and its app version.
+func LatestChartVersion(repo string, chart string) (string, string, error) {
+	versions, err := chartVersions(repo, chart)
+	if err != nil {
+		return "", "", err
+	}
+
+	latest, err := latestChartVersion(versions)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s", err, chart)
+	}
+
+	return latest.Version, latest.AppVersion, nil
+}
+
+// isPrerelease checks if a semantic version has a prerelease suffix.
+func isPrerelease(version string) bool {
+	version, _, _ = strings.Cut(version, "+")
+	return strings.Contains(version, "-")
+}
+
+// compareVersions compares two semantic versions and returns -1, 0 or
+// +1 depending on whether a is lower, equal or higher than b. A leading
+// "v" and build metadata are ignored. Prereleases are lower than the
+// release they precede, but are otherwise compared lexically.
+func compareVersions(a string, b string) int {
+	a, _, _ = strings.Cut(strings.TrimPrefix(a, "v"), "+")
+	b, _, _ = strings.Cut(strings.TrimPrefix(b, "v"), "+")
+
+	aCore, aPre, aIsPre := strings.Cut(a, "-")
+	bCore, bPre, bIsPre := strings.Cut(b, "-")
+
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+
+		if aPart != bPart {
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aIsPre == bIsPre:
+		return strings.Compare(aPre, bPre)
+	case aIsPre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// resolveAddonVersion resolves the chart version and app version that
+// the helm controller will install for the addon. Unpinned addons are
+// resolved to the latest stable version of the chart.
+func resolveAddonVersion(addon Addon, versions []chartVersion) (string, string, error) {
+	if addon.Version == "" {
+		latest, err := latestChartVersion(versions)
+		return latest.Version, latest.AppVersion, err
+	}
+
+	for _, version := range versions {
+		if version.Version == addon.Version {
+			return version.Version, version.AppVersion, nil
+		}
+	}
+
+	return addon.Version, "", errors.New("chart version not found: " + addon.Version)
+}
+
+// manifestDir returns the directory that k3s deploys manifests from.
+// The data directory of the cluster takes precedence over the one of
+// the node, just like when the configuration is rendered.
+func (e *Engine) manifestDir(server *Node) string {
+	dataDir := DefaultDataDir
+	if e.Spec.Cluster.Server.DataDir != "" {
+		dataDir = e.Spec.Cluster.Server.DataDir
+	} else if server.Server.DataDir != "" {
+		dataDir = server.Server.DataDir
+	}
+
+	return path.Join(dataDir, "server", "manifests")
+}
+
+// addonManifest returns the location of the manifest of an addon.
+func (e *Engine) addonManifest(server *Node, name string) string {
+	return path.Join(e.manifestDir(server), Program+"-"+name+".yaml")
+}
+
+// DeployAddons writes the HelmChart manifests of all addons to the first
+// server, which are then deployed by k3s, and records the requested
+// versions. Addons that were removed from the configuration are never
+// deleted automatically as this uninstalls the chart. They are only
+// forgotten once their manifest was removed manually.
+func (e *Engine) DeployAddons() error {
+	server := e.FilterNodes(RoleServer)[0]
+
+	requested, err := e.requestedAddons(server)
+	if err != nil {
+		return err
+	}
+
+	// Skip recording the state if there is nothing to do.
+	if len(e.Spec.Addons) == 0 && len(requested) == 0 {
+		return nil
+	}
+
+	configured := make(map[string]bool)
+	for _, addon := range e.Spec.Addons {
+		configured[addon.Name] = true
+
+		// Resolve the version that the helm controller will install. This
+		// is not fatal as the upstream repository may not be reachable.
+		version, appVersion := addon.Version, ""
+		versions, err := chartVersions(addon.Repo, addon.Chart)
+		if err == nil {
+			version, appVersion, err = resolveAddonVersion(addon, versions)
+		}
+		if err != nil {
+			server.Logger.Warn().Err(err).Str("addon", addon.Name).Msg("Failed to resolve addon version")
+		}
+
+		manifest := helmChart{
+			APIVersion: "helm.cattle.io/v1",
+			Kind:       "HelmChart",
+		}
+		manifest.Metadata.Name = addon.Name
+		manifest.Metadata.Namespace = "kube-system"
+		manifest.Spec.Repo = addon.Repo
+		manifest.Spec.Chart = addon.Chart
+		manifest.Spec.Version = version
+		manifest.Spec.TargetNamespace = addon.Namespace
+		manifest.Spec.CreateNamespace = addon.Namespace != ""
+
+		if len(addon.Values) > 0 {
+			valuesBytes, err := yaml.Marshal(addon.Values)
+			if err != nil {
+				return err
+			}
+			manifest.Spec.ValuesContent = string(valuesBytes)
+		}
+
+		manifestBytes, err := yaml.Marshal(&manifest)
+		if err != nil {
+			return err
+		}
+
+		server.Logger.Info().Str("addon", addon.Name).Str("version", version).Msg("Deploying addon")
+
+		tmpManifest := "/tmp/k3se/addon-" + addon.Name + ".yaml"
+		if err := server.Upload(tmpManifest, bytes.NewReader(manifestBytes)); err != nil {
+			return err
+		}
+
+		if err := server.Do(sshx.Cmd{
+			Cmd: fmt.Sprintf("sudo mkdir -m 755 -p %s && sudo chown root:root %s && sudo mv %s %s",
+				e.manifestDir(server), tmpManifest, tmpManifest, e.addonManifest(server, addon.Name)),
+		}); err != nil {
+			return err
+		}
+
+		requested[addon.Name] = RequestedAddon{
+			Chart:      addon.Chart,
+			Version:    version,
+			AppVersion: appVersion,
+		}
+	}
+
+	for name := range requested {
+		if configured[name] {
+			continue
+		}
+
+		manifestBuffer := new(bytes.Buffer)
+		if err := server.Do(sshx.Cmd{
+			Cmd:    fmt.Sprintf(`sudo sh -c "test -f %s && echo present || true"`, e.addonManifest(server, name)),
+			Stdout: manifestBuffer,
+		}); err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(manifestBuffer.String()) == "" {
+			delete(requested, name)
+			continue
+		}
+
+		server.Logger.Warn().Str("addon", name).Msg("Addon is no longer configured and needs to be removed manually")
+	}
+
+	return e.recordAddons(server, requested)
+}
+
+// AddonStatus compares the requested versions of all addons with the
+// configured versions and the latest versions that are available upstream.
+func (e *Engine) AddonStatus() ([]AddonStatus, error) {
+	requested, err := e.requestedAddons(e.FilterNodes(RoleServer)[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []AddonStatus
+	configured := make(map[string]bool)
+	for _, addon := range e.Spec.Addons {
+		configured[addon.Name] = true
+
+		status := AddonStatus{
+			Name:           addon.Name,
+			Chart:          addon.Chart,
+			DesiredVersion: addon.Version,
+		}
+
+		// Ignore the requested state if the addon now refers to another chart.
+		if state, ok := requested[addon.Name]; ok && state.Chart == addon.Chart {
+			status.Deployed = true
+			status.RequestedVersion = state.Version
+			status.RequestedAppVersion = state.AppVersion
+		}
+
+		status.LatestVersion, status.LatestAppVersion, err = LatestChartVersion(addon.Repo, addon.Chart)
+		if err != nil {
+			e.Logger.Warn().Err(err).Str("addon", addon.Name).Msg("Failed to fetch latest addon version")
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	var removed []string
+	for name := range requested {
+		if !configured[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	for _, name := range removed {
+		state := requested[name]
+		statuses = append(statuses, AddonStatus{
+			Name:                name,
+			Chart:               state.Chart,
+			Deployed:            true,
+			Removed:             true,
+			RequestedVersion:    state.Version,
+			RequestedAppVersion: state.AppVersion,
+		})
+	}
+
+	return statuses, nil
+}
+
+// requestedAddons reads the requested addons recorded on the server.
+func (e *Engine) requestedAddons(server *Node) (map[string]RequestedAddon, error) {
+	stateBuffer := new(bytes.Buffer)
+	if err := server.Do(sshx.Cmd{
+		Cmd:    fmt.Sprintf(`sudo sh -c "cat %s 2>/dev/null || true"`, path.Join(StateDir, "addons.yaml")),
+		Stdout: stateBuffer,
+	}); err != nil {
+		return nil, err
+	}
+
+	requested := make(map[string]RequestedAddon)
+	if err := yaml.Unmarshal(stateBuffer.Bytes(), &requested); err != nil {
+		return nil, err
+	}
+
+	return requested, nil
+}
+
+// recordAddons records the requested addons on the server.
+func (e *Engine) recordAddons(server *Node, requested map[string]RequestedAddon) error {
+	stateBytes, err := yaml.Marshal(requested)
+	if err != nil {
+		return err
+	}
+
+	tmpState := "/tmp/k3se/addons.yaml"
+	if err := server.Upload(tmpState, bytes.NewReader(stateBytes)); err != nil {
+		return err
+	}
+
+	return server.Do(sshx.Cmd{
+		Cmd: fmt.Sprintf("sudo mkdir -m 755 -p %s && sudo chown root:root %s && sudo mv %s %s",
+			StateDir, tmpState, tmpState, path.Join(StateDir, "addons.yaml")),
+	})
+}
@@ -0,0 +1,114 @@
+package engine
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+build", "1.2.3", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.3", "1.2.10", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3-rc1", "1.2.3", -1},
+		{"1.2.3", "1.2.3-rc1", 1},
+		{"1.2.3-alpha", "1.2.3-beta", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLatestChartVersion(t *testing.T) {
+	versions := []chartVersion{
+		{Version: "1.9.0", AppVersion: "v1.9.0"},
+		{Version: "1.11.0-rc1", AppVersion: "v1.11.0-rc1"},
+		{Version: "1.10.2", AppVersion: "v1.10.2"},
+		{Version: "1.10.0", AppVersion: "v1.10.0"},
+	}
+
+	latest, err := latestChartVersion(versions)
+	if err != nil {
+		t.Fatalf("latestChartVersion() error = %v", err)
+	}
+	if latest.Version != "1.10.2" || latest.AppVersion != "v1.10.2" {
+		t.Errorf("latestChartVersion() = %v, want 1.10.2", latest)
+	}
+
+	if _, err := latestChartVersion([]chartVersion{{Version: "1.0.0-rc1"}}); err == nil {
+		t.Error("latestChartVersion() expected error without stable versions")
+	}
+}
+
+func TestResolveAddonVersion(t *testing.T) {
+	versions := []chartVersion{
+		{Version: "1.10.2", AppVersion: "v1.10.2"},
+		{Version: "1.9.0", AppVersion: "v1.9.0"},
+	}
+
+	version, appVersion, err := resolveAddonVersion(Addon{Version: "1.9.0"}, versions)
+	if err != nil || version != "1.9.0" || appVersion != "v1.9.0" {
+		t.Errorf("resolveAddonVersion() = %q, %q, %v, want pinned version", version, appVersion, err)
+	}
+
+	version, appVersion, err = resolveAddonVersion(Addon{}, versions)
+	if err != nil || version != "1.10.2" || appVersion != "v1.10.2" {
+		t.Errorf("resolveAddonVersion() = %q, %q, %v, want latest version", version, appVersion, err)
+	}
+
+	version, _, err = resolveAddonVersion(Addon{Version: "0.1.0"}, versions)
+	if err == nil || version != "0.1.0" {
+		t.Errorf("resolveAddonVersion() = %q, %v, want configured version and error", version, err)
+	}
+}
+
+func TestAddonStatusAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		status AddonStatus
+		want   Action
+	}{
+		{"not deployed", AddonStatus{LatestVersion: "1.0.0"}, ActionInstall},
+		{"removed", AddonStatus{Deployed: true, Removed: true}, ActionRemove},
+		{"up to date", AddonStatus{Deployed: true, RequestedVersion: "1.0.0", LatestVersion: "1.0.0"}, ""},
+		{"pinned", AddonStatus{Deployed: true, RequestedVersion: "1.0.0", DesiredVersion: "1.0.0", LatestVersion: "1.1.0"}, ""},
+		{"outdated", AddonStatus{Deployed: true, RequestedVersion: "1.0.0", LatestVersion: "1.1.0"}, ActionUpgrade},
+		{"unresolved", AddonStatus{Deployed: true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.Action(); got != tt.want {
+				t.Errorf("Action() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddonStatusUpdateAvailable(t *testing.T) {
+	tests := []struct {
+		requested, latest string
+		want              bool
+	}{
+		{"1.9.0", "1.10.0", true},
+		{"1.10.0", "1.9.0", false},
+		{"1.10.0", "1.10.0", false},
+		{"1.10.0", "1.11.0-rc1", false},
+		{"", "1.10.0", false},
+		{"1.10.0", "", false},
+	}
+
+	for _, tt := range tests {
+		status := AddonStatus{RequestedVersion: tt.requested, LatestVersion: tt.latest}
+		if got := status.UpdateAvailable(); got != tt.want {
+			t.Errorf("UpdateAvailable(%q, %q) = %v, want %v", tt.requested, tt.latest, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Fault is a failure that can be injected into the operations on a node.
+type Fault string
+
+const (
+	// FaultSSHDrop closes the SSH connection of the node before the
+	// first command or upload, which then fail like on a real drop.
+	FaultSSHDrop Fault = "ssh-drop"
+	// FaultSlowUpload throttles every file upload to the node.
+	FaultSlowUpload Fault = "slow-upload"
+	// FaultInstallerFailure fails the installation script on the node.
+	FaultInstallerFailure Fault = "installer-failure"
+)
+
+var (
+	// Faults is a list of the faults that can be injected.
+	Faults = []Fault{FaultSSHDrop, FaultSlowUpload, FaultInstallerFailure}
+
+	// ErrInjectedFault is returned by operations that failed due to an injected fault.
+	ErrInjectedFault = errors.New("injected fault")
+)
+
+// Chaos describes the faults that are injected into the operations on
+// nodes. It allows to rehearse how pipelines and the engine behave when
+// failures occur and must never be used against production clusters.
+type Chaos struct {
+	// Faults maps the name of a node to the faults injected on the node.
+	Faults map[string][]Fault
+	// UploadRate is the rate in bytes per second that uploads are
+	// throttled to if the slow upload fault is injected.
+	UploadRate int
+}
+
+// ParseChaos parses a comma-separated list of faults per node, such
+// as "kube1=ssh-drop,kube2=slow-upload,kube2=installer-failure".
+func ParseChaos(spec string) (*Chaos, error) {
+	chaos := &Chaos{
+		Faults:     make(map[string][]Fault),
+		UploadRate: 4096,
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		name, fault, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid fault specification: %s", entry)
+		}
+
+		valid := false
+		for _, f := range Faults {
+			if Fault(fault) == f {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unsupported fault: %s", fault)
+		}
+
+		chaos.Faults[name] = append(chaos.Faults[name], Fault(fault))
+	}
+
+	return chaos, nil
+}
+
+// hasFault checks if the fault is injected on the node.
+func (node *Node) hasFault(fault Fault) bool {
+	for _, f := range node.faults {
+		if f == fault {
+			return true
+		}
+	}
+
+	return false
+}
+
+// injectFault returns an error if the fault is injected on the node.
+func (node *Node) injectFault(fault Fault) error {
+	if !node.hasFault(fault) {
+		return nil
+	}
+
+	node.Logger.Warn().Str("fault", string(fault)).Msg("Injecting fault")
+
+	return fmt.Errorf("%w: %s", ErrInjectedFault, fault)
+}
+
+// dropConnection closes the SSH connection of the node once if the
+// fault is injected. Subsequent operations on the node are expected
+// to fail the same way as they would after a real connection drop.
+func (node *Node) dropConnection() {
+	if node.dropped || !node.hasFault(FaultSSHDrop) {
+		return
+	}
+
+	node.Logger.Warn().Str("fault", string(FaultSSHDrop)).Msg("Injecting fault")
+
+	node.dropped = true
+	if node.Client != nil && node.Client.SSH != nil {
+		node.Client.SSH.Close()
+	}
+}
+
+// throttledReader limits the rate at which data is read from a reader.
+type throttledReader struct {
+	src  io.Reader
+	rate int
+}
+
+// Read reads small chunks and delays each chunk according to the rate.
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.rate <= 0 {
+		return r.src.Read(p)
+	}
+
+	// Read small chunks to make the transfer progress steadily.
+	chunk := r.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if len(p) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := r.src.Read(p)
+	time.Sleep(time.Duration(n) * time.Second / time.Duration(r.rate))
+
+	return n, err
+}
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChaos(t *testing.T) {
+	chaos, err := ParseChaos("kube1=ssh-drop, kube2=slow-upload,kube2=installer-failure")
+	if err != nil {
+		t.Fatalf("ParseChaos() error = %v", err)
+	}
+
+	want := map[string][]Fault{
+		"kube1": {FaultSSHDrop},
+		"kube2": {FaultSlowUpload, FaultInstallerFailure},
+	}
+	if !reflect.DeepEqual(chaos.Faults, want) {
+		t.Errorf("ParseChaos() faults = %v, want %v", chaos.Faults, want)
+	}
+	if chaos.UploadRate <= 0 {
+		t.Errorf("ParseChaos() upload rate = %d, want positive", chaos.UploadRate)
+	}
+
+	for _, spec := range []string{"", "kube1", "=ssh-drop", "kube1=unknown", "kube1=ssh-drop,"} {
+		if _, err := ParseChaos(spec); err == nil {
+			t.Errorf("ParseChaos(%q) expected error", spec)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package engine
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
+	"dario.cat/mergo"
 	"github.com/nicklasfrahm/k3se/pkg/sshx"
 	"gopkg.in/yaml.v3"
 )
@@ -24,12 +27,31 @@ const (
 var (
 	// Channels is a list of the available release channels.
 	Channels = []string{"stable", "latest", "testing"}
+
+	// versionPattern matches a specific k3s release, such as "v1.30.5+k3s1".
+	versionPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+(-rc\d+)?\+k3s\d+$`)
+
+	// addonNamePattern matches a DNS-1123 label, which is required
+	// for the name of the HelmChart resource of an addon.
+	addonNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
 )
 
 // Cluster defines share settings across all servers and agents.
 type Cluster struct {
 	Server Server `yaml:"server,omitempty"`
 	Agent  Agent  `yaml:"agent,omitempty"`
+
+	// ExtraConfig is merged verbatim into the k3s configuration
+	// of all servers and agents respectively.
+	ExtraConfig ClusterExtraConfig `yaml:"extra-config,omitempty"`
+}
+
+// ClusterExtraConfig allows to specify k3s options that are not
+// modeled by k3se yet. Use with caution as these options are not
+// validated and are passed to k3s as is.
+type ClusterExtraConfig struct {
+	Server map[string]interface{} `yaml:"server,omitempty"`
+	Agent  map[string]interface{} `yaml:"agent,omitempty"`
 }
 
 // Config describes the state of a k3s cluster. For general
@@ -40,6 +62,14 @@ type Config struct {
 	// channel as specified in the k3s installation options.
 	Version string `yaml:"version"`
 
+	// Secure enables the hardened mode. It is equivalent
+	// to passing the --secure flag on the command line.
+	Secure bool `yaml:"secure,omitempty"`
+
+	// InstallerChecksum is the expected SHA256 checksum of the
+	// k3s installation script. It is required in secure mode.
+	InstallerChecksum string `yaml:"installer-checksum,omitempty"`
+
 	// Cluster defines shared configuration settings across all
 	// servers and agents.
 	Cluster Cluster `yaml:"cluster"`
@@ -52,6 +82,14 @@ type Config struct {
 	// for an SSH proxy, often also referred to as bastion
 	// host or jumpbox.
 	SSHProxy sshx.Config `yaml:"ssh-proxy"`
+
+	// Hooks are local commands that are executed before and
+	// after the cluster is deployed.
+	Hooks Hooks `yaml:"hooks,omitempty"`
+
+	// Addons is a list of Helm charts that are deployed
+	// via the helm controller that is built into k3s.
+	Addons []Addon `yaml:"addons,omitempty"`
 }
 
 // Verify verifies the configuration file.
@@ -69,8 +107,8 @@ func (c *Config) Verify() error {
 			break
 		}
 	}
-	if !channelValid {
-		return errors.New("unsupported version must be one of: " + strings.Join(Channels, ", "))
+	if !channelValid && !versionPattern.MatchString(c.Version) {
+		return errors.New("unsupported version must be a k3s release or one of: " + strings.Join(Channels, ", "))
 	}
 
 	if c.Nodes == nil || len(c.Nodes) == 0 {
@@ -78,10 +116,29 @@ func (c *Config) Verify() error {
 	}
 
 	var controlPlanes = 0
+	var names = make(map[string]bool)
 	for _, node := range c.Nodes {
 		if node.Role == RoleServer {
 			controlPlanes += 1
 		}
+
+		// Nodes without a name may share a host, e.g. via port-forwarding.
+		if node.Name != "" {
+			if names[node.Name] {
+				return errors.New("duplicate node name: " + node.Name)
+			}
+			names[node.Name] = true
+		}
+	}
+
+	// A name must not match the host of another node, as nodes
+	// are identified by their name or, if unset, by their host.
+	for i, node := range c.Nodes {
+		for j, other := range c.Nodes {
+			if i != j && node.Name != "" && node.Name == other.SSH.Host {
+				return errors.New("node name conflicts with host of another node: " + node.Name)
+			}
+		}
 	}
 
 	if controlPlanes == 0 {
@@ -92,6 +149,38 @@ func (c *Config) Verify() error {
 		return errors.New("number of control-plane nodes must be odd")
 	}
 
+	var addons = make(map[string]bool)
+	for _, addon := range c.Addons {
+		if addon.Name == "" || addon.Repo == "" || addon.Chart == "" {
+			return errors.New("addons must specify a name, a repo and a chart")
+		}
+
+		if !addonNamePattern.MatchString(addon.Name) {
+			return errors.New("addon name must be a DNS-1123 label: " + addon.Name)
+		}
+
+		if addons[addon.Name] {
+			return errors.New("duplicate addon name: " + addon.Name)
+		}
+		addons[addon.Name] = true
+	}
+
+	// Verify the effective server configuration of every control-plane.
+	for _, node := range c.Nodes {
+		if node.Role != RoleServer {
+			continue
+		}
+
+		server := node.Server
+		if err := mergo.Merge(&server, c.Cluster.Server, mergo.WithOverride); err != nil {
+			return err
+		}
+
+		if err := server.Verify(); err != nil {
+			return fmt.Errorf("invalid server configuration for node %s: %w", node.ID(), err)
+		}
+	}
+
 	return nil
 }
 
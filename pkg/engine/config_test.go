@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionPattern(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"v1.30.5+k3s1", true},
+		{"v1.31.0-rc1+k3s2", true},
+		{"v1.30.5", false},
+		{"1.30.5+k3s1", false},
+		{"v1.30+k3s1", false},
+		{"stable", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := versionPattern.MatchString(tt.version); got != tt.want {
+			t.Errorf("versionPattern.MatchString(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyAddonNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"cert-manager", false},
+		{"a", false},
+		{"ingress2", false},
+		{"Cert-Manager", true},
+		{"-cert-manager", true},
+		{"cert-manager-", true},
+		{"cert_manager", true},
+		{"cert-manager; rm -rf /", true},
+		{"../cert-manager", true},
+		{strings.Repeat("a", 64), true},
+	}
+
+	for _, tt := range tests {
+		config := &Config{
+			Version: "stable",
+			Nodes:   []Node{{Role: RoleServer}},
+			Addons:  []Addon{{Name: tt.name, Repo: "https://charts.jetstack.io", Chart: "cert-manager"}},
+		}
+
+		if err := config.Verify(); (err != nil) != tt.wantErr {
+			t.Errorf("Verify() with addon %q error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyNodeNames(t *testing.T) {
+	server := func(name string, host string) Node {
+		node := Node{Name: name, Role: RoleServer}
+		node.SSH.Host = host
+		return node
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []Node
+		wantErr bool
+	}{
+		{"unique names", []Node{server("kube1", "10.0.0.1"), server("kube2", "10.0.0.2"), server("kube3", "10.0.0.3")}, false},
+		{"shared host without names", []Node{server("", "localhost"), server("", "localhost"), server("", "localhost")}, false},
+		{"name equals own host", []Node{server("10.0.0.1", "10.0.0.1")}, false},
+		{"duplicate names", []Node{server("kube1", "10.0.0.1"), server("kube1", "10.0.0.2"), server("kube3", "10.0.0.3")}, true},
+		{"name equals host of other node", []Node{server("10.0.0.2", "10.0.0.1"), server("", "10.0.0.2"), server("kube3", "10.0.0.3")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Version: "stable", Nodes: tt.nodes}
+			if err := config.Verify(); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package engine
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -21,6 +23,10 @@ import (
 
 const (
 	InstallerURL = "https://get.k3s.io"
+
+	// TokenFile is the location of the cluster token on joining
+	// nodes if the engine is running in secure mode.
+	TokenFile = "/etc/rancher/k3s/token"
 )
 
 // Engine is a type that encapsulates parts of the installation logic.
@@ -32,6 +38,11 @@ type Engine struct {
 	clusterToken   string
 	serverURL      string
 	cleanupPending bool
+	secure         bool
+	selection      []string
+	chaos          *Chaos
+	sshProxy       *sshx.Client
+	release        string
 
 	Spec *Config
 }
@@ -45,6 +56,8 @@ func New(options ...Option) (*Engine, error) {
 
 	return &Engine{
 		Logger: opts.Logger,
+		secure: opts.Secure,
+		chaos:  opts.Chaos,
 	}, nil
 }
 
@@ -69,6 +82,64 @@ func (e *Engine) FilterNodes(selector Role) []*Node {
 	return nodes
 }
 
+// Select restricts operations that target individual nodes, such as
+// Exec, Status and Plan, to the nodes with the specified names. Only
+// the selected nodes and the first server, which holds the cluster
+// state, are connected to. If no names are specified, all nodes are
+// selected. Operations that affect the whole cluster, such as Install
+// and Uninstall, refuse to run with a selection.
+func (e *Engine) Select(names ...string) error {
+	for _, name := range names {
+		if e.findNode(name) == nil {
+			return errors.New("unknown node: " + name)
+		}
+	}
+
+	e.selection = names
+
+	return nil
+}
+
+// findNode returns the node with the specified name or nil if
+// there is no such node.
+func (e *Engine) findNode(name string) *Node {
+	for _, node := range e.FilterNodes(RoleAny) {
+		if node.ID() == name {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// isSelected checks if the node is part of the selection of the engine.
+func (e *Engine) isSelected(node *Node) bool {
+	if len(e.selection) == 0 {
+		return true
+	}
+
+	for _, name := range e.selection {
+		if node.ID() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectNodes returns the nodes that match the selector
+// and that are part of the selection of the engine.
+func (e *Engine) selectNodes(selector Role) []*Node {
+	var selected []*Node
+	for _, node := range e.FilterNodes(selector) {
+		if e.isSelected(node) {
+			selected = append(selected, node)
+		}
+	}
+
+	return selected
+}
+
 // SetSpec configures the desired state of the cluster. Note
 // that the config will only be applied if the verification
 // succeeds.
@@ -77,8 +148,40 @@ func (e *Engine) SetSpec(config *Config) error {
 		return err
 	}
 
+	if config.Secure {
+		e.secure = true
+	}
+
 	e.Spec = config
 
+	// Prevent known secrets from leaking into the logs.
+	if e.secure {
+		for _, node := range e.FilterNodes(RoleAny) {
+			for _, secret := range []string{
+				node.SSH.Password,
+				node.SSH.Passphrase,
+				node.SSH.Key,
+				e.Spec.SSHProxy.Password,
+				e.Spec.SSHProxy.Passphrase,
+				e.Spec.SSHProxy.Key,
+				node.Server.EtcdS3SecretKey,
+				e.Spec.Cluster.Server.EtcdS3SecretKey,
+			} {
+				node.Redact(secret)
+			}
+		}
+	}
+
+	if e.chaos != nil {
+		for name := range e.chaos.Faults {
+			if e.findNode(name) == nil {
+				return errors.New("unknown node in chaos specification: " + name)
+			}
+		}
+
+		e.Logger.Warn().Msg("Chaos mode is enabled: faults will be injected!")
+	}
+
 	port := 6443
 	if e.Spec.Cluster.Server.HTTPSListenPort != 0 {
 		port = e.Spec.Cluster.Server.HTTPSListenPort
@@ -98,13 +201,57 @@ func (e *Engine) SetSpec(config *Config) error {
 	return nil
 }
 
-// ConfigureNode uploads the installer and the configuration
-// to a node prior to running the installation script.
+// ConfigureNode uploads the configuration to a node prior to running
+// the installation script. The phase is skipped if the same configuration
+// was already written during a previous, interrupted run.
 func (e *Engine) ConfigureNode(node *Node) error {
 	e.cleanupPending = true
 
+	// TODO: Make the engine smarter by checking if the node has multiple interfaces
+	//       and configuring the "node-ip" if HA is enabled.
+
+	configBytes, err := e.renderConfig(node)
+	if err != nil {
+		return err
+	}
+
+	skip, err := e.skipPhase(node, PhaseConfigure, IdempotencyKey(PhaseConfigure, string(configBytes)))
+	if err != nil || skip {
+		return err
+	}
+
 	node.Logger.Info().Msg("Configuring node")
 
+	if err := node.Upload("/tmp/k3se/config.yaml", bytes.NewReader(configBytes)); err != nil {
+		return err
+	}
+
+	if err := node.Do(sshx.Cmd{
+		Cmd: "sudo mkdir -m 755 -p /etc/rancher/k3s",
+	}); err != nil {
+		return err
+	}
+
+	if err := node.Do(sshx.Cmd{
+		Cmd: "sudo chown root:root /tmp/k3se/config.yaml && sudo mv /tmp/k3se/config.yaml /etc/rancher/k3s",
+	}); err != nil {
+		return err
+	}
+
+	return node.CompletePhase(PhaseConfigure, node.keys[PhaseConfigure])
+}
+
+// installNode uploads and runs the installation script on the node. The
+// phase is skipped if the installation was already completed with the
+// same configuration during a previous, interrupted run.
+func (e *Engine) installNode(node *Node, env map[string]string) error {
+	// The token is not part of the key as it is only known at runtime.
+	key := IdempotencyKey(PhaseInstall, node.keys[PhaseConfigure], e.release, env["INSTALL_K3S_EXEC"], env["K3S_URL"])
+	skip, err := e.skipPhase(node, PhaseInstall, key)
+	if err != nil || skip {
+		return err
+	}
+
 	installer, err := e.fetchInstallationScript()
 	if err != nil {
 		return err
@@ -120,71 +267,145 @@ func (e *Engine) ConfigureNode(node *Node) error {
 		return err
 	}
 
-	// TODO: Make the engine smarter by checking if the node has multiple interfaces
-	//       and configuring the "node-ip" if HA is enabled.
+	if _, ok := env["K3S_URL"]; ok {
+		if err := e.configureClusterToken(node, env); err != nil {
+			return err
+		}
+	}
+
+	node.Logger.Info().Msg("Running installation script")
+	if err := node.injectFault(FaultInstallerFailure); err != nil {
+		return err
+	}
+	if err := node.Do(sshx.Cmd{
+		Cmd:    "/tmp/k3se/install.sh",
+		Env:    env,
+		Stdout: node,
+	}); err != nil {
+		return err
+	}
+
+	return node.CompletePhase(PhaseInstall, key)
+}
+
+// renderConfig creates the server or agent configuration of the node.
+// The extra configuration of the cluster and the node is merged verbatim
+// into the result to allow for options that are not modeled yet. The
+// node is not modified, which allows the configuration to be rendered
+// multiple times, e.g. for a plan followed by an installation.
+func (e *Engine) renderConfig(node *Node) ([]byte, error) {
+	var config interface{}
+	var extraConfig map[string]interface{}
 
-	// Create server or agent configuration.
-	var configBytes []byte
 	if node.Role == RoleServer {
+		server := node.Server
+
 		// This ensures that agents can connect to the servers in Vagrant. For reference, see:
 		// https://github.com/alexellis/k3sup/issues/306#issuecomment-1059986048
-		if node.Server.AdvertiseAddress == "" {
-			node.Server.AdvertiseAddress = node.SSH.Host
+		if server.AdvertiseAddress == "" {
+			server.AdvertiseAddress = node.SSH.Host
 		}
 
-		if err := mergo.Merge(&node.Server, e.Spec.Cluster.Server, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
-			return err
+		if server.NodeName == "" {
+			server.NodeName = node.Name
 		}
 
-		configBytes, err = yaml.Marshal(&node.Server)
-		if err != nil {
-			return err
+		if err := mergo.Merge(&server, e.Spec.Cluster.Server, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+			return nil, err
 		}
+
+		config = &server
+		extraConfig = e.Spec.Cluster.ExtraConfig.Server
 	}
 
 	if node.Role == RoleAgent {
-		if err := mergo.Merge(&node.Agent, e.Spec.Cluster.Agent, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
-			return err
+		agent := node.Agent
+
+		if agent.NodeName == "" {
+			agent.NodeName = node.Name
 		}
 
-		configBytes, err = yaml.Marshal(&node.Agent)
-		if err != nil {
-			return err
+		if err := mergo.Merge(&agent, e.Spec.Cluster.Agent, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+			return nil, err
 		}
+
+		config = &agent
+		extraConfig = e.Spec.Cluster.ExtraConfig.Agent
 	}
 
-	if err := node.Upload("/tmp/k3se/config.yaml", bytes.NewReader(configBytes)); err != nil {
-		return err
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := node.Do(sshx.Cmd{
-		Cmd: "sudo mkdir -m 755 -p /etc/rancher/k3s",
-	}); err != nil {
-		return err
+	if len(extraConfig) == 0 && len(node.ExtraConfig) == 0 {
+		return configBytes, nil
 	}
 
-	if err := node.Do(sshx.Cmd{
-		Cmd: "sudo chown root:root /tmp/k3se/config.yaml && sudo mv /tmp/k3se/config.yaml /etc/rancher/k3s",
-	}); err != nil {
-		return err
+	// Convert the configuration into a map to merge the extra configuration.
+	configMap := make(map[string]interface{})
+	if err := yaml.Unmarshal(configBytes, &configMap); err != nil {
+		return nil, err
 	}
 
-	return nil
+	// Settings of the node take precedence over settings of the cluster.
+	for key, value := range extraConfig {
+		configMap[key] = value
+	}
+	for key, value := range node.ExtraConfig {
+		configMap[key] = value
+	}
+
+	node.Logger.Warn().Msg("Using extra configuration that is not validated by k3se")
+
+	return yaml.Marshal(configMap)
 }
 
-// Install runs the installation script on the node.
+// Install runs the installation script on all nodes. The phase markers
+// are only removed once all nodes were installed successfully, which
+// allows an interrupted installation to resume where it left off.
 func (e *Engine) Install() error {
+	if len(e.selection) > 0 {
+		return errors.New("node selection is not supported for installations")
+	}
+
 	e.Logger.Info().Str("server_url", e.serverURL).Msg("Detecting server URL")
 
+	// Resolve the release of the channel to ensure that a resumed run
+	// does not skip nodes that were installed from an older release.
+	release, err := ResolveVersion(e.Spec.Version)
+	if err != nil {
+		return err
+	}
+	e.release = release
+
 	if err := e.installControlPlanes(); err != nil {
 		return err
 	}
 
-	return e.installWorkers()
+	if err := e.installWorkers(); err != nil {
+		return err
+	}
+
+	if err := e.DeployAddons(); err != nil {
+		return err
+	}
+
+	for _, node := range e.FilterNodes(RoleAny) {
+		if err := node.ResetPhases(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Uninstall runs the uninstallation script on all nodes.
 func (e *Engine) Uninstall() error {
+	if len(e.selection) > 0 {
+		return errors.New("node selection is not supported for uninstallations")
+	}
+
 	// Get a list of all nodes.
 	nodes := e.FilterNodes(RoleAny)
 	for _, node := range nodes {
@@ -203,6 +424,12 @@ func (e *Engine) Uninstall() error {
 		}); err != nil {
 			return err
 		}
+
+		if err := node.Do(sshx.Cmd{
+			Cmd: "sudo rm -rf " + StateDir,
+		}); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -211,14 +438,20 @@ func (e *Engine) Uninstall() error {
 // Connect establishes an SSH connection to all nodes.
 func (e *Engine) Connect() error {
 	// Establish connection to proxy if host is specified.
-	var sshProxy *sshx.Client
 	if e.Spec.SSHProxy.Host != "" {
+		var sshOptions []sshx.Option
+		if e.secure {
+			sshOptions = append(sshOptions, sshx.WithStrictHostKey(), sshx.WithPasswordAuthDisabled())
+		}
+
 		var err error
-		if sshProxy, err = sshx.NewClient(&e.Spec.SSHProxy); err != nil {
+		if e.sshProxy, err = sshx.NewClient(&e.Spec.SSHProxy, sshOptions...); err != nil {
 			return err
 		}
 	}
 
+	firstControlplane := e.FilterNodes(RoleServer)[0]
+
 	// Get a list of all nodes and connect to them.
 	for i := 0; i < len(e.Spec.Nodes); i++ {
 		// We need to create a proper handle here as the nodes in the Spec
@@ -226,9 +459,24 @@ func (e *Engine) Connect() error {
 		node := &e.Spec.Nodes[i]
 
 		// Inject logger into node.
-		node.Logger = e.Logger.With().Str("host", node.SSH.Host).Logger()
+		logger := e.Logger.With().Str("host", node.SSH.Host)
+		if node.Name != "" {
+			logger = logger.Str("node", node.Name)
+		}
+		node.Logger = logger.Logger()
+
+		// Inject faults into the node if chaos mode is enabled.
+		if e.chaos != nil {
+			node.faults = e.chaos.Faults[node.ID()]
+			node.uploadRate = e.chaos.UploadRate
+		}
+
+		// Nodes that are not selected do not need to be reachable.
+		if node != firstControlplane && !e.isSelected(node) {
+			continue
+		}
 
-		if err := node.Connect(WithSSHProxy(sshProxy), WithLogger(&node.Logger)); err != nil {
+		if err := node.Connect(WithSSHProxy(e.sshProxy), WithLogger(&node.Logger), WithSecure(e.secure)); err != nil {
 			return err
 		}
 	}
@@ -236,27 +484,41 @@ func (e *Engine) Connect() error {
 	return nil
 }
 
-// Disconnect closes all SSH connections to all nodes.
+// Disconnect closes all SSH connections to all nodes and the proxy.
+// It is safe to call Disconnect multiple times and after a failed
+// Connect. All connections are closed even if some of them fail.
 func (e *Engine) Disconnect() error {
-	nodes := e.FilterNodes(RoleAny)
+	var errs []error
+
+	for _, node := range e.FilterNodes(RoleAny) {
+		// Skip nodes that were never connected to.
+		if node.Client == nil {
+			continue
+		}
 
-	for _, node := range nodes {
 		// Clean up temporary files before disconnecting.
 		if e.cleanupPending {
 			node.Logger.Info().Msg("Cleaning up temporary files")
 			if err := node.Do(sshx.Cmd{
 				Cmd: "rm -rf /tmp/k3se",
 			}); err != nil {
-				return err
+				errs = append(errs, err)
 			}
 		}
 
 		if err := node.Disconnect(); err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	if e.sshProxy != nil {
+		if err := e.sshProxy.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		e.sshProxy = nil
+	}
+
+	return errors.Join(errs...)
 }
 
 // KubeConfig writes the kubeconfig of the cluster to the specified location.
@@ -356,24 +618,43 @@ func (e *Engine) KubeConfig(outputPath string) error {
 }
 
 // fetchInstallationScript returns the downloaded the k3s installer.
+// If an installer checksum is configured, the checksum of the
+// downloaded installer is verified.
 func (e *Engine) fetchInstallationScript() ([]byte, error) {
+	return e.fetchInstallationScriptFrom(InstallerURL)
+}
+
+// fetchInstallationScriptFrom downloads the installation script from the URL.
+func (e *Engine) fetchInstallationScriptFrom(installerURL string) ([]byte, error) {
 	// Lock engine to prevent concurrent access to installer cache.
 	e.Lock()
+	defer e.Unlock()
 
 	if len(e.installer) == 0 {
-		resp, err := http.Get(InstallerURL)
+		if e.secure && e.Spec.InstallerChecksum == "" {
+			return nil, errors.New("installer checksum must be specified in secure mode")
+		}
+
+		resp, err := http.Get(installerURL)
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
 
-		e.installer, err = io.ReadAll(resp.Body)
+		installer, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return nil, err
 		}
-	}
 
-	e.Unlock()
+		if e.Spec.InstallerChecksum != "" {
+			checksum := fmt.Sprintf("%x", sha256.Sum256(installer))
+			if !strings.EqualFold(checksum, strings.TrimPrefix(e.Spec.InstallerChecksum, "sha256:")) {
+				return nil, fmt.Errorf("installer checksum mismatch: downloaded installer checksum: %s", checksum)
+			}
+		}
+
+		e.installer = installer
+	}
 
 	return e.installer, nil
 }
@@ -390,18 +671,63 @@ func (e *Engine) fetchClusterToken(server *Node) error {
 
 	e.clusterToken = strings.TrimSpace(tokenBuffer.String())
 
+	// Prevent the token from leaking into the logs.
+	if e.secure {
+		for _, node := range e.FilterNodes(RoleAny) {
+			node.Redact(e.clusterToken)
+		}
+	}
+
 	return nil
 }
 
-// installControlPlanes installs the k3s servers.
-func (e *Engine) installControlPlanes() error {
-	// These installation options are universal to HA and non-HA clusters.
+// configureClusterToken configures the environment of the installer
+// to join the cluster. In secure mode, the token is placed in a file
+// on the node instead of being passed via an environment variable,
+// which would expose it in the process list of the node.
+func (e *Engine) configureClusterToken(node *Node, env map[string]string) error {
+	if !e.secure {
+		env["K3S_TOKEN"] = e.clusterToken
+		return nil
+	}
+
+	if err := node.UploadSecret("/tmp/k3se/token", strings.NewReader(e.clusterToken)); err != nil {
+		return err
+	}
+
+	if err := node.Do(sshx.Cmd{
+		Cmd: "sudo chown root:root /tmp/k3se/token && sudo mv /tmp/k3se/token " + TokenFile,
+	}); err != nil {
+		return err
+	}
+
+	env["K3S_TOKEN_FILE"] = TokenFile
+
+	return nil
+}
+
+// installerEnv returns the environment of the installation script,
+// which either installs a specific version or a release channel.
+func (e *Engine) installerEnv(exec string) map[string]string {
 	env := map[string]string{
 		"INSTALL_K3S_FORCE_RESTART": "true",
-		"INSTALL_K3S_EXEC":          "server",
-		"INSTALL_K3s_CHANNEL":       e.Spec.Version,
+		"INSTALL_K3S_EXEC":          exec,
+	}
+
+	if versionPattern.MatchString(e.Spec.Version) {
+		env["INSTALL_K3S_VERSION"] = e.Spec.Version
+	} else {
+		env["INSTALL_K3S_CHANNEL"] = e.Spec.Version
 	}
 
+	return env
+}
+
+// installControlPlanes installs the k3s servers.
+func (e *Engine) installControlPlanes() error {
+	// These installation options are universal to HA and non-HA clusters.
+	env := e.installerEnv("server")
+
 	servers := e.FilterNodes(RoleServer)
 
 	// Enable HA mode if we have more than a single control-plane.
@@ -418,15 +744,9 @@ func (e *Engine) installControlPlanes() error {
 
 		if i > 0 {
 			env["K3S_URL"] = e.serverURL
-			env["K3S_TOKEN"] = e.clusterToken
 		}
 
-		server.Logger.Info().Msg("Running installation script")
-		if err := server.Do(sshx.Cmd{
-			Cmd:    "/tmp/k3se/install.sh",
-			Env:    env,
-			Stdout: server,
-		}); err != nil {
+		if err := e.installNode(server, env); err != nil {
 			return err
 		}
 
@@ -443,41 +763,37 @@ func (e *Engine) installControlPlanes() error {
 func (e *Engine) installWorkers() error {
 	agents := e.FilterNodes(RoleAgent)
 
-	if len(agents) > 0 {
-		wg := sync.WaitGroup{}
+	if len(agents) == 0 {
+		return nil
+	}
 
-		for _, agent := range agents {
-			wg.Add(1)
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(agents))
 
-			go func(agent *Node) {
-				defer wg.Done()
+	for i, agent := range agents {
+		wg.Add(1)
 
-				if err := e.ConfigureNode(agent); err != nil {
-					agent.Logger.Error().Err(err).Msg("Failed to configure node")
-					return
-				}
+		go func(i int, agent *Node) {
+			defer wg.Done()
 
-				agent.Logger.Info().Msg("Running installation script")
-				if err := agent.Do(sshx.Cmd{
-					Cmd: "/tmp/k3se/install.sh",
-					Env: map[string]string{
-						"INSTALL_K3S_FORCE_RESTART": "true",
-						"INSTALL_K3S_EXEC":          "agent",
-						"INSTALL_K3s_CHANNEL":       e.Spec.Version,
-						"K3S_TOKEN":                 e.clusterToken,
-						"K3S_URL":                   e.serverURL,
-					},
-					Stdout: agent,
-				}); err != nil {
-					agent.Logger.Error().Err(err).Msg("Failed to run installation script")
-					return
-				}
+			if err := e.ConfigureNode(agent); err != nil {
+				agent.Logger.Error().Err(err).Msg("Failed to configure node")
+				errs[i] = err
+				return
+			}
 
-			}(agent)
-		}
+			env := e.installerEnv("agent")
+			env["K3S_URL"] = e.serverURL
 
-		wg.Wait()
+			if err := e.installNode(agent, env); err != nil {
+				agent.Logger.Error().Err(err).Msg("Failed to run installation script")
+				errs[i] = err
+				return
+			}
+		}(i, agent)
 	}
 
-	return nil
+	wg.Wait()
+
+	return errors.Join(errs...)
 }
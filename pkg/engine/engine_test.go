@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInstallerEnv(t *testing.T) {
+	tests := []struct {
+		version string
+		key     string
+		absent  string
+	}{
+		{"stable", "INSTALL_K3S_CHANNEL", "INSTALL_K3S_VERSION"},
+		{"v1.30.5+k3s1", "INSTALL_K3S_VERSION", "INSTALL_K3S_CHANNEL"},
+	}
+
+	for _, tt := range tests {
+		e := &Engine{Spec: &Config{Version: tt.version}}
+		env := e.installerEnv("server")
+
+		if env["INSTALL_K3S_EXEC"] != "server" {
+			t.Errorf("installerEnv() INSTALL_K3S_EXEC = %q, want %q", env["INSTALL_K3S_EXEC"], "server")
+		}
+		if env[tt.key] != tt.version {
+			t.Errorf("installerEnv() %s = %q, want %q", tt.key, env[tt.key], tt.version)
+		}
+		if _, ok := env[tt.absent]; ok {
+			t.Errorf("installerEnv() unexpectedly sets %s", tt.absent)
+		}
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	e := &Engine{Spec: &Config{
+		Cluster: Cluster{
+			Server: Server{
+				WriteKubeconfigMode: "644",
+				TLSSAN:              []string{"cluster.example.com"},
+			},
+			ExtraConfig: ClusterExtraConfig{
+				Server: map[string]interface{}{
+					"embedded-registry": true,
+					"flannel-backend":   "wireguard-native",
+				},
+			},
+		},
+	}}
+
+	node := &Node{
+		Name:   "kube1",
+		Role:   RoleServer,
+		Logger: zerolog.Nop(),
+		Server: Server{TLSSAN: []string{"kube1.example.com"}},
+		ExtraConfig: map[string]interface{}{
+			"flannel-backend": "host-gw",
+		},
+	}
+	node.SSH.Host = "10.0.0.1"
+
+	configBytes, err := e.renderConfig(node)
+	if err != nil {
+		t.Fatalf("renderConfig() error = %v", err)
+	}
+
+	config := make(map[string]interface{})
+	if err := yaml.Unmarshal(configBytes, &config); err != nil {
+		t.Fatalf("failed to parse rendered config: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"advertise-address":     "10.0.0.1",
+		"node-name":             "kube1",
+		"write-kubeconfig-mode": "644",
+		"tls-san":               []interface{}{"kube1.example.com", "cluster.example.com"},
+		"embedded-registry":     true,
+		"flannel-backend":       "host-gw",
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("renderConfig() = %v, want %v", config, want)
+	}
+}
+
+func TestRenderConfigIsRepeatable(t *testing.T) {
+	e := &Engine{Spec: &Config{
+		Cluster: Cluster{
+			Server: Server{TLSSAN: []string{"cluster.example.com"}},
+		},
+	}}
+
+	node := &Node{
+		Name:   "kube1",
+		Role:   RoleServer,
+		Logger: zerolog.Nop(),
+		Server: Server{TLSSAN: []string{"kube1.example.com"}},
+	}
+	node.SSH.Host = "10.0.0.1"
+
+	first, err := e.renderConfig(node)
+	if err != nil {
+		t.Fatalf("renderConfig() error = %v", err)
+	}
+
+	second, err := e.renderConfig(node)
+	if err != nil {
+		t.Fatalf("renderConfig() error = %v", err)
+	}
+
+	if string(first) != string(second) {
+		t.Errorf("renderConfig() is not repeatable:\n%s\n---\n%s", first, second)
+	}
+	if !reflect.DeepEqual(node.Server, Server{TLSSAN: []string{"kube1.example.com"}}) {
+		t.Errorf("renderConfig() modified the node: %+v", node.Server)
+	}
+}
+
+func TestFetchInstallationScript(t *testing.T) {
+	installer := []byte("#!/bin/sh\necho k3s\n")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(installer))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(installer)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		secure   bool
+		checksum string
+		wantErr  bool
+	}{
+		{"no checksum", false, "", false},
+		{"matching checksum", false, checksum, false},
+		{"prefixed checksum", true, "sha256:" + checksum, false},
+		{"checksum mismatch", false, fmt.Sprintf("%x", sha256.Sum256([]byte("tampered"))), true},
+		{"secure without checksum", true, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Engine{
+				secure: tt.secure,
+				Spec:   &Config{InstallerChecksum: tt.checksum},
+			}
+
+			got, err := e.fetchInstallationScriptFrom(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchInstallationScriptFrom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != string(installer) {
+				t.Errorf("fetchInstallationScriptFrom() = %q, want %q", got, installer)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	kube1 := Node{Name: "kube1", Role: RoleServer}
+	kube1.SSH.Host = "10.0.0.1"
+	unnamed := Node{Role: RoleAgent}
+	unnamed.SSH.Host = "10.0.0.2"
+
+	e := &Engine{Spec: &Config{Nodes: []Node{kube1, unnamed}}}
+
+	if err := e.Select("kube2"); err == nil {
+		t.Error("Select() expected error for unknown node")
+	}
+	if err := e.Select("10.0.0.1"); err == nil {
+		t.Error("Select() expected error for host of a named node")
+	}
+	if got := e.selectNodes(RoleAny); len(got) != 2 {
+		t.Errorf("selectNodes() without selection = %d nodes, want 2", len(got))
+	}
+
+	if err := e.Select("10.0.0.2"); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+
+	selected := e.selectNodes(RoleAny)
+	if len(selected) != 1 || selected[0].SSH.Host != "10.0.0.2" {
+		t.Errorf("selectNodes() = %v, want node with host 10.0.0.2", selected)
+	}
+	if got := e.selectNodes(RoleServer); len(got) != 0 {
+		t.Errorf("selectNodes(RoleServer) = %d nodes, want 0", len(got))
+	}
+}
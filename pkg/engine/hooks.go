@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/nicklasfrahm/k3se/pkg/sshx"
+)
+
+// Stage is the point in time at which a hook is executed.
+type Stage string
+
+const (
+	// StagePre is the stage before the cluster is deployed.
+	StagePre Stage = "pre"
+	// StagePost is the stage after the cluster is deployed.
+	StagePost Stage = "post"
+)
+
+// Hooks describes local commands that are executed before and after
+// the cluster is deployed. Each command is executed once per node and
+// receives the metadata of the node, the facts gathered from the node
+// and the custom environment of the node via environment variables.
+type Hooks struct {
+	Pre  []string `yaml:"pre,omitempty"`
+	Post []string `yaml:"post,omitempty"`
+}
+
+// NodeMetadata describes a node for consumption by hook scripts.
+type NodeMetadata struct {
+	Name       string            `json:"name"`
+	Host       string            `json:"host"`
+	Port       int               `json:"port"`
+	User       string            `json:"user"`
+	Role       Role              `json:"role"`
+	Tags       []string          `json:"tags,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Facts      map[string]string `json:"facts,omitempty"`
+	SSHCommand string            `json:"sshCommand"`
+}
+
+// Metadata returns the metadata of the node. The proxy is only
+// used to build the SSH command line and may be nil. Facts are
+// not included as they need to be gathered via Facts.
+func (node *Node) Metadata(proxy *Node) NodeMetadata {
+	return NodeMetadata{
+		Name:       node.ID(),
+		Host:       node.SSH.Host,
+		Port:       node.SSH.Port,
+		User:       node.SSH.User,
+		Role:       node.Role,
+		Tags:       node.Tags,
+		Env:        node.Env,
+		SSHCommand: node.SSHCommand(proxy),
+	}
+}
+
+// Facts gathers information about the operating system of the node,
+// such as "arch", "kernel", "hostname", "os" and "os-version".
+func (node *Node) Facts() (map[string]string, error) {
+	factsBuffer := new(bytes.Buffer)
+	if err := node.Do(sshx.Cmd{
+		Cmd: `echo "arch=$(uname -m)"; echo "kernel=$(uname -r)"; echo "hostname=$(hostname)"; ` +
+			`if [ -f /etc/os-release ]; then . /etc/os-release; echo "os=$ID"; echo "os-version=$VERSION_ID"; fi`,
+		Shell:  true,
+		Stdout: factsBuffer,
+	}); err != nil {
+		return nil, err
+	}
+
+	return parseFacts(factsBuffer.String()), nil
+}
+
+// parseFacts parses lines of "key=value" pairs and ignores empty values.
+func parseFacts(output string) map[string]string {
+	facts := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && value != "" {
+			facts[key] = value
+		}
+	}
+
+	return facts
+}
+
+// SSHCommand returns an OpenSSH command line that connects to the
+// node. Arguments are quoted for the shell, which is why the command
+// needs to be evaluated, e.g. via `eval "$K3SE_SSH_COMMAND" uptime`.
+// Keys that are specified inline can not be passed to the command
+// line and need to be loaded into an SSH agent instead.
+func (node *Node) SSHCommand(proxy *Node) string {
+	args := []string{"ssh"}
+
+	if node.SSH.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(node.SSH.Port))
+	}
+	if node.SSH.KeyFile != "" {
+		args = append(args, "-i", node.SSH.KeyFile)
+	}
+	if proxy != nil && proxy.SSH.Host != "" {
+		proxyTarget := proxy.SSH.Host
+		if proxy.SSH.User != "" {
+			proxyTarget = proxy.SSH.User + "@" + proxyTarget
+		}
+
+		// A jump host can not be given a key of its own, which is
+		// why a proxy command is used if the proxy has a key file.
+		if proxy.SSH.KeyFile != "" {
+			proxyArgs := []string{"ssh", "-i", proxy.SSH.KeyFile}
+			if proxy.SSH.Port != 0 {
+				proxyArgs = append(proxyArgs, "-p", strconv.Itoa(proxy.SSH.Port))
+			}
+			proxyArgs = append(proxyArgs, "-W", "%h:%p", proxyTarget)
+
+			args = append(args, "-o", "ProxyCommand="+shellJoin(proxyArgs))
+		} else {
+			if proxy.SSH.Port != 0 {
+				proxyTarget = fmt.Sprintf("%s:%d", proxyTarget, proxy.SSH.Port)
+			}
+			args = append(args, "-J", proxyTarget)
+		}
+	}
+
+	target := node.SSH.Host
+	if node.SSH.User != "" {
+		target = node.SSH.User + "@" + target
+	}
+
+	return shellJoin(append(args, target))
+}
+
+// shellJoin quotes the arguments for the shell where necessary.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@%=") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// RunHooks executes the hooks of the specified stage for every node.
+// The metadata of all nodes is written to a JSON file, whose path is
+// passed to the hooks via the K3SE_NODES_FILE environment variable.
+func (e *Engine) RunHooks(stage Stage) error {
+	hooks := e.Spec.Hooks.Pre
+	if stage == StagePost {
+		hooks = e.Spec.Hooks.Post
+	}
+
+	// Skip writing the metadata file if there is nothing to do.
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	proxy := &Node{SSH: e.Spec.SSHProxy}
+	nodes := e.FilterNodes(RoleAny)
+
+	metadata := make([]NodeMetadata, len(nodes))
+	for i, node := range nodes {
+		metadata[i] = node.Metadata(proxy)
+
+		facts, err := node.Facts()
+		if err != nil {
+			return err
+		}
+		metadata[i].Facts = facts
+	}
+
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", Program+"-nodes-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(metadataBytes); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	for i, node := range nodes {
+		env := append(os.Environ(),
+			"K3SE_STAGE="+string(stage),
+			"K3SE_NODES_FILE="+file.Name(),
+			"K3SE_NODE_NAME="+metadata[i].Name,
+			"K3SE_NODE_HOST="+metadata[i].Host,
+			"K3SE_NODE_PORT="+strconv.Itoa(metadata[i].Port),
+			"K3SE_NODE_USER="+metadata[i].User,
+			"K3SE_NODE_ROLE="+string(metadata[i].Role),
+			"K3SE_NODE_TAGS="+strings.Join(metadata[i].Tags, ","),
+			"K3SE_SSH_COMMAND="+metadata[i].SSHCommand,
+		)
+		for key, value := range metadata[i].Facts {
+			env = append(env, "K3SE_NODE_FACT_"+strings.ToUpper(strings.ReplaceAll(key, "-", "_"))+"="+value)
+		}
+		for key, value := range metadata[i].Env {
+			env = append(env, key+"="+value)
+		}
+
+		for _, hook := range hooks {
+			node.Logger.Info().Str("stage", string(stage)).Str("hook", hook).Msg("Running hook")
+
+			cmd := exec.Command("sh", "-c", hook)
+			cmd.Env = env
+			cmd.Stdout = node
+			cmd.Stderr = node
+
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("%s hook failed on %s: %w", stage, metadata[i].Name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nicklasfrahm/k3se/pkg/sshx"
+)
+
+func TestSSHCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		node  sshx.Config
+		proxy *sshx.Config
+		want  string
+	}{
+		{
+			name: "host only",
+			node: sshx.Config{Host: "10.0.0.1"},
+			want: "ssh 10.0.0.1",
+		},
+		{
+			name: "user, port and key",
+			node: sshx.Config{Host: "10.0.0.1", User: "k3se", Port: 2222, KeyFile: "/home/k3se/.ssh/id_ed25519"},
+			want: "ssh -p 2222 -i /home/k3se/.ssh/id_ed25519 k3se@10.0.0.1",
+		},
+		{
+			name: "key with spaces and quotes",
+			node: sshx.Config{Host: "10.0.0.1", KeyFile: "/home/k3se/my keys/it's"},
+			want: `ssh -i '/home/k3se/my keys/it'"'"'s' 10.0.0.1`,
+		},
+		{
+			name:  "jump host",
+			node:  sshx.Config{Host: "10.0.0.1", User: "k3se"},
+			proxy: &sshx.Config{Host: "bastion.example.com", User: "jump"},
+			want:  "ssh -J jump@bastion.example.com k3se@10.0.0.1",
+		},
+		{
+			name:  "jump host with port",
+			node:  sshx.Config{Host: "10.0.0.1"},
+			proxy: &sshx.Config{Host: "bastion.example.com", Port: 2222},
+			want:  "ssh -J bastion.example.com:2222 10.0.0.1",
+		},
+		{
+			name:  "proxy with key",
+			node:  sshx.Config{Host: "10.0.0.1", User: "k3se"},
+			proxy: &sshx.Config{Host: "bastion.example.com", User: "jump", Port: 2222, KeyFile: "/keys/bastion"},
+			want:  `ssh -o 'ProxyCommand=ssh -i /keys/bastion -p 2222 -W %h:%p jump@bastion.example.com' k3se@10.0.0.1`,
+		},
+		{
+			name:  "proxy with key containing spaces",
+			node:  sshx.Config{Host: "10.0.0.1"},
+			proxy: &sshx.Config{Host: "bastion.example.com", KeyFile: "/my keys/bastion"},
+			want:  `ssh -o 'ProxyCommand=ssh -i '"'"'/my keys/bastion'"'"' -W %h:%p bastion.example.com' 10.0.0.1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &Node{SSH: tt.node}
+
+			var proxy *Node
+			if tt.proxy != nil {
+				proxy = &Node{SSH: *tt.proxy}
+			}
+
+			if got := node.SSHCommand(proxy); got != tt.want {
+				t.Errorf("SSHCommand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	args := []string{"plain", "with space", "it's", `"double"`, "$HOME", "`id`", "", "a;b", "-o", "ProxyCommand=ssh -i 'k' -W %h:%p"}
+
+	// Evaluating the joined arguments must yield the original arguments.
+	output, err := exec.Command("sh", "-c", `eval "set -- $0"; for arg in "$@"; do printf '%s\n' "$arg"; done`, shellJoin(args)).Output()
+	if err != nil {
+		t.Fatalf("failed to evaluate arguments: %v", err)
+	}
+
+	got := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("shellJoin() evaluates to %q, want %q", got, args)
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	output := "arch=x86_64\nkernel=6.8.0-45-generic\nhostname=kube1\nos=ubuntu\nos-version=24.04\n\nempty=\ngarbage\n"
+
+	want := map[string]string{
+		"arch":       "x86_64",
+		"kernel":     "6.8.0-45-generic",
+		"hostname":   "kube1",
+		"os":         "ubuntu",
+		"os-version": "24.04",
+	}
+	if got := parseFacts(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFacts() = %v, want %v", got, want)
+	}
+}
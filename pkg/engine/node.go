@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -10,7 +13,12 @@ import (
 	"github.com/rs/zerolog"
 )
 
-var loglevel = regexp.MustCompile(`\[([^\]]*)\]\s*`)
+var (
+	loglevel = regexp.MustCompile(`\[([^\]]*)\]\s*`)
+
+	// ErrNotConnected is returned by operations on nodes that are not connected.
+	ErrNotConnected = errors.New("node not connected")
+)
 
 const (
 	// Program is used to configure the name of the configuration file.
@@ -19,13 +27,42 @@ const (
 
 // Node describes the configuration of a node.
 type Node struct {
+	// Name is the identity of the node. It is used as the Kubernetes node
+	// name and is decoupled from the SSH host, which allows the address of
+	// a node to change without changing its identity. Defaults to the host.
+	Name   string      `yaml:"name,omitempty"`
 	Role   Role        `yaml:"role"`
 	SSH    sshx.Config `yaml:"ssh"`
 	Server Server      `yaml:"server,omitempty"`
 	Agent  Agent       `yaml:"agent,omitempty"`
+	Tags   []string    `yaml:"tags,omitempty"`
+
+	// Env is a set of custom environment variables that
+	// are passed to hooks when they run for this node.
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// ExtraConfig is merged verbatim into the k3s configuration of the
+	// node and takes precedence over the extra configuration of the cluster.
+	ExtraConfig map[string]interface{} `yaml:"extra-config,omitempty"`
 
 	Client *sshx.Client   `yaml:"-"`
 	Logger zerolog.Logger `yaml:"-"`
+
+	secrets    []string
+	keys       map[Phase]string
+	faults     []Fault
+	uploadRate int
+	dropped    bool
+}
+
+// ID returns the name of the node or its
+// SSH host if no name is specified.
+func (node *Node) ID() string {
+	if node.Name != "" {
+		return node.Name
+	}
+
+	return node.SSH.Host
 }
 
 // Connect establishes a connection to the node.
@@ -35,11 +72,16 @@ func (node *Node) Connect(options ...Option) error {
 		return err
 	}
 
-	node.Client, err = sshx.NewClient(&node.SSH,
+	sshOptions := []sshx.Option{
 		sshx.WithProxy(opts.SSHProxy),
 		sshx.WithLogger(opts.Logger),
 		sshx.WithTimeout(opts.Timeout),
-	)
+	}
+	if opts.Secure {
+		sshOptions = append(sshOptions, sshx.WithStrictHostKey(), sshx.WithPasswordAuthDisabled())
+	}
+
+	node.Client, err = sshx.NewClient(&node.SSH, sshOptions...)
 	if err != nil {
 		return err
 	}
@@ -49,15 +91,41 @@ func (node *Node) Connect(options ...Option) error {
 
 // Disconnect closes the connection to the node.
 func (node *Node) Disconnect() error {
-	if node.Client != nil {
-		return node.Client.Close()
+	if node.Client == nil {
+		return nil
 	}
 
-	return nil
+	client := node.Client
+	node.Client = nil
+
+	return client.Close()
 }
 
 // Upload writes the specified content to the remote file on the node.
 func (node *Node) Upload(dst string, src io.Reader) error {
+	return node.upload(dst, src, 0644)
+}
+
+// UploadSecret writes the specified content to the remote file
+// on the node, ensuring that only the owner can read the file.
+func (node *Node) UploadSecret(dst string, src io.Reader) error {
+	return node.upload(dst, src, 0600)
+}
+
+// upload writes the specified content to the remote file on the node
+// and restricts the permissions before any content is written.
+func (node *Node) upload(dst string, src io.Reader, mode os.FileMode) error {
+	if node.Client == nil {
+		return fmt.Errorf("%w: %s", ErrNotConnected, node.ID())
+	}
+
+	node.dropConnection()
+
+	if node.hasFault(FaultSlowUpload) {
+		node.Logger.Warn().Str("fault", string(FaultSlowUpload)).Int("rate", node.uploadRate).Msg("Injecting fault")
+		src = &throttledReader{src: src, rate: node.uploadRate}
+	}
+
 	// Get base directory for the file.
 	dir := filepath.Dir(dst)
 
@@ -74,7 +142,7 @@ func (node *Node) Upload(dst string, src io.Reader) error {
 	defer file.Close()
 
 	// Restrict permissions.
-	if err := node.Client.SFTP.Chmod(dst, 0644); err != nil {
+	if err := node.Client.SFTP.Chmod(dst, mode); err != nil {
 		return err
 	}
 
@@ -90,16 +158,34 @@ func (node *Node) Upload(dst string, src io.Reader) error {
 
 // Do executes a command on the node.
 func (node *Node) Do(cmd sshx.Cmd) error {
+	if node.Client == nil {
+		return fmt.Errorf("%w: %s", ErrNotConnected, node.ID())
+	}
+
+	node.dropConnection()
+
 	return node.Client.Do(cmd)
 }
 
+// Redact ensures that the specified secret is never
+// written to the logs of the node.
+func (node *Node) Redact(secret string) {
+	if secret != "" {
+		node.secrets = append(node.secrets, secret)
+	}
+}
+
 // Write writes a log information for the node.
 // TODO: Make this more efficient by reducing allocations.
 func (node *Node) Write(raw []byte) (int, error) {
 	// Remove the log level supplied by the k3s install script.
-	trimmed := loglevel.ReplaceAll(raw, []byte(""))
+	trimmed := string(loglevel.ReplaceAll(raw, []byte("")))
+
+	for _, secret := range node.secrets {
+		trimmed = strings.ReplaceAll(trimmed, secret, "[REDACTED]")
+	}
 
-	lines := strings.Split(string(trimmed), "\n")
+	lines := strings.Split(trimmed, "\n")
 	for i := 0; i < len(lines)-1; i++ {
 		line := strings.TrimSpace(lines[i])
 		if line != "" {
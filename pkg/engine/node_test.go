@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nicklasfrahm/k3se/pkg/sshx"
+)
+
+func TestNodeNotConnected(t *testing.T) {
+	node := &Node{Name: "kube1"}
+
+	if err := node.Do(sshx.Cmd{Cmd: "true"}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Do() error = %v, want %v", err, ErrNotConnected)
+	}
+	if err := node.Upload("/tmp/k3se/test", strings.NewReader("test")); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Upload() error = %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestNodeWriteRedactsSecrets(t *testing.T) {
+	output := new(bytes.Buffer)
+	node := &Node{Logger: zerolog.New(output)}
+	node.Redact("hunter2")
+	node.Redact("")
+
+	if _, err := node.Write([]byte("[INFO]  token=hunter2\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if strings.Contains(output.String(), "hunter2") {
+		t.Errorf("Write() leaked a secret: %s", output.String())
+	}
+	if !strings.Contains(output.String(), "token=[REDACTED]") {
+		t.Errorf("Write() did not redact the secret: %s", output.String())
+	}
+}
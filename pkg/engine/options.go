@@ -13,6 +13,8 @@ type Options struct {
 	Logger   *zerolog.Logger
 	SSHProxy *sshx.Client
 	Timeout  time.Duration
+	Secure   bool
+	Chaos    *Chaos
 }
 
 // Option applies a configuration option
@@ -65,3 +67,23 @@ func WithTimeout(timeout time.Duration) Option {
 		return nil
 	}
 }
+
+// WithSecure enables the hardened mode, which enforces host key
+// verification, public key authentication, the usage of token
+// files, installer checksums and the redaction of secrets in logs.
+func WithSecure(secure bool) Option {
+	return func(options *Options) error {
+		options.Secure = secure
+		return nil
+	}
+}
+
+// WithChaos injects the specified faults into the operations on
+// nodes. This is intended for testing and must never be used in
+// production.
+func WithChaos(chaos *Chaos) Option {
+	return func(options *Options) error {
+		options.Chaos = chaos
+		return nil
+	}
+}
@@ -1,5 +1,7 @@
 package engine
 
+import "errors"
+
 // Server describes the configuration of a k3s server. For more information, please refer to the k3s documentation:
 // https://rancher.com/docs/k3s/latest/en/installation/install-options/server-config/#k3s-server-cli-help
 type Server struct {
@@ -81,3 +83,16 @@ type Server struct {
 	LBServerPort            int    `yaml:"lb-server-port,omitempty"`
 	// Deprecated options, such as "--no-flannel", are omitted.
 }
+
+// Verify checks that the combination of etcd snapshot options is valid.
+func (s *Server) Verify() error {
+	if s.EtcdS3 && s.EtcdS3Bucket == "" {
+		return errors.New("etcd-s3 requires etcd-s3-bucket")
+	}
+
+	if (s.EtcdS3AccessKey == "") != (s.EtcdS3SecretKey == "") {
+		return errors.New("etcd-s3-access-key and etcd-s3-secret-key must be specified together")
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package engine
+
+import "testing"
+
+func TestServerVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  Server
+		wantErr bool
+	}{
+		{"empty", Server{}, false},
+		{"local snapshots", Server{EtcdSnapshotScheduleCron: "0 */6 * * *"}, false},
+		{"s3 with bucket", Server{EtcdS3: true, EtcdS3Bucket: "backups"}, false},
+		{"s3 with credentials", Server{EtcdS3: true, EtcdS3Bucket: "backups", EtcdS3AccessKey: "access", EtcdS3SecretKey: "secret"}, false},
+		{"s3 without bucket", Server{EtcdS3: true}, true},
+		{"access key only", Server{EtcdS3: true, EtcdS3Bucket: "backups", EtcdS3AccessKey: "access"}, true},
+		{"secret key only", Server{EtcdS3: true, EtcdS3Bucket: "backups", EtcdS3SecretKey: "secret"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.server.Verify(); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nicklasfrahm/k3se/pkg/sshx"
+)
+
+const (
+	// StateDir is the directory on the node that holds the state of k3se.
+	StateDir = "/var/lib/k3se"
+)
+
+// Phase is a step of an operation that is performed on a node.
+type Phase string
+
+const (
+	// PhaseConfigure is the phase that writes the k3s configuration.
+	PhaseConfigure Phase = "configure"
+	// PhaseInstall is the phase that runs the installation script.
+	PhaseInstall Phase = "install"
+)
+
+// IdempotencyKey derives a key for a phase from all inputs that
+// affect the outcome of the phase. If any of the inputs change,
+// the key changes and the phase will be performed again.
+func IdempotencyKey(phase Phase, inputs ...string) string {
+	hash := sha256.New()
+	hash.Write([]byte(phase))
+	for _, input := range inputs {
+		// Separate the inputs to prevent ambiguous concatenations.
+		hash.Write([]byte{0})
+		hash.Write([]byte(input))
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// phaseMarker returns the location of the marker of a phase.
+func phaseMarker(phase Phase) string {
+	return path.Join(StateDir, "phases", string(phase))
+}
+
+// PhaseCompleted checks if the phase was previously completed
+// on the node with the same idempotency key.
+func (node *Node) PhaseCompleted(phase Phase, key string) (bool, error) {
+	markerBuffer := new(bytes.Buffer)
+	if err := node.Do(sshx.Cmd{
+		Cmd:    fmt.Sprintf(`sudo sh -c "cat %s 2>/dev/null || true"`, phaseMarker(phase)),
+		Stdout: markerBuffer,
+	}); err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(markerBuffer.String()) == key, nil
+}
+
+// CompletePhase records the completion of the phase on the node.
+func (node *Node) CompletePhase(phase Phase, key string) error {
+	tmpMarker := "/tmp/k3se/phase-" + string(phase)
+	if err := node.Upload(tmpMarker, strings.NewReader(key+"\n")); err != nil {
+		return err
+	}
+
+	return node.Do(sshx.Cmd{
+		Cmd: fmt.Sprintf("sudo mkdir -m 755 -p %s && sudo chown root:root %s && sudo mv %s %s",
+			path.Dir(phaseMarker(phase)), tmpMarker, tmpMarker, phaseMarker(phase)),
+	})
+}
+
+// ResetPhases removes all phase markers from the node.
+func (node *Node) ResetPhases() error {
+	return node.Do(sshx.Cmd{
+		Cmd: "sudo rm -rf " + path.Dir(phaseMarker(PhaseInstall)),
+	})
+}
+
+// skipPhase checks if the phase can be skipped on the node and
+// remembers the key to mark the phase as completed later on.
+func (e *Engine) skipPhase(node *Node, phase Phase, key string) (bool, error) {
+	if node.keys == nil {
+		node.keys = make(map[Phase]string)
+	}
+	node.keys[phase] = key
+
+	completed, err := node.PhaseCompleted(phase, key)
+	if err != nil {
+		return false, err
+	}
+
+	if completed {
+		node.Logger.Info().Str("phase", string(phase)).Str("key", key[:12]).Msg("Skipping completed phase")
+	}
+
+	return completed, nil
+}
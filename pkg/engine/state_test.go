@@ -0,0 +1,23 @@
+package engine
+
+import "testing"
+
+func TestIdempotencyKey(t *testing.T) {
+	key := IdempotencyKey(PhaseInstall, "a", "b")
+
+	if len(key) != 64 {
+		t.Errorf("IdempotencyKey() length = %d, want 64", len(key))
+	}
+	if got := IdempotencyKey(PhaseInstall, "a", "b"); got != key {
+		t.Errorf("IdempotencyKey() is not deterministic: %s != %s", got, key)
+	}
+	if got := IdempotencyKey(PhaseConfigure, "a", "b"); got == key {
+		t.Error("IdempotencyKey() does not depend on the phase")
+	}
+	if got := IdempotencyKey(PhaseInstall, "ab"); got == key {
+		t.Error("IdempotencyKey() does not separate the inputs")
+	}
+	if got := IdempotencyKey(PhaseInstall, "a", "c"); got == key {
+		t.Error("IdempotencyKey() does not depend on the inputs")
+	}
+}
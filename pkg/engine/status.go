@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/nicklasfrahm/k3se/pkg/sshx"
+)
+
+const (
+	// ChannelURL is the base URL of the k3s release channel server.
+	ChannelURL = "https://update.k3s.io/v1-release/channels"
+)
+
+// Action is a change that is required to bring a node up to date.
+type Action string
+
+const (
+	// ActionInstall installs k3s on a node that does not run k3s yet.
+	ActionInstall Action = "install"
+	// ActionUpgrade changes the version of k3s on a node.
+	ActionUpgrade Action = "upgrade"
+	// ActionConfigure updates the k3s configuration of a node.
+	ActionConfigure Action = "configure"
+	// ActionRemove marks an addon that is no longer configured and
+	// needs to be removed manually.
+	ActionRemove Action = "remove"
+)
+
+// NodeStatus describes the observed state of a node.
+type NodeStatus struct {
+	Name      string
+	Host      string
+	Role      Role
+	Installed bool
+	Active    bool
+	Version   string
+}
+
+// PlanStep describes the changes that are pending for a node.
+type PlanStep struct {
+	Name           string
+	Host           string
+	Role           Role
+	CurrentVersion string
+	DesiredVersion string
+	Actions        []Action
+}
+
+// ClusterStatus describes the observed state of the cluster.
+type ClusterStatus struct {
+	Nodes  []NodeStatus
+	Addons []AddonStatus
+}
+
+// ClusterPlan describes the changes that are pending for the cluster.
+type ClusterPlan struct {
+	Nodes  []PlanStep
+	Addons []AddonStatus
+}
+
+// Status fetches the observed state of all nodes and addons.
+func (e *Engine) Status() (*ClusterStatus, error) {
+	nodes := e.selectNodes(RoleAny)
+
+	status := &ClusterStatus{
+		Nodes: make([]NodeStatus, len(nodes)),
+	}
+	for i, node := range nodes {
+		nodeStatus, err := e.nodeStatus(node)
+		if err != nil {
+			return nil, err
+		}
+		status.Nodes[i] = *nodeStatus
+	}
+
+	addons, err := e.AddonStatus()
+	if err != nil {
+		return nil, err
+	}
+	status.Addons = addons
+
+	return status, nil
+}
+
+// Plan computes the changes that an installation would perform
+// without applying them. The rendered configuration of each node
+// is compared against the configuration that is present on the node.
+func (e *Engine) Plan() (*ClusterPlan, error) {
+	desiredVersion, err := ResolveVersion(e.Spec.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := e.selectNodes(RoleAny)
+
+	steps := make([]PlanStep, len(nodes))
+	for i, node := range nodes {
+		status, err := e.nodeStatus(node)
+		if err != nil {
+			return nil, err
+		}
+
+		configBytes, err := e.renderConfig(node)
+		if err != nil {
+			return nil, err
+		}
+
+		currentConfig := new(bytes.Buffer)
+		if err := node.Do(sshx.Cmd{
+			Cmd:    `sudo sh -c "cat /etc/rancher/k3s/config.yaml 2>/dev/null || true"`,
+			Stdout: currentConfig,
+		}); err != nil {
+			return nil, err
+		}
+
+		steps[i] = PlanStep{
+			Name:           status.Name,
+			Host:           status.Host,
+			Role:           status.Role,
+			CurrentVersion: status.Version,
+			DesiredVersion: desiredVersion,
+			Actions:        planActions(status, desiredVersion, configBytes, currentConfig.Bytes()),
+		}
+	}
+
+	addons, err := e.AddonStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusterPlan{
+		Nodes:  steps,
+		Addons: addons,
+	}, nil
+}
+
+// planActions computes the actions that are required to bring
+// a node to the desired version and the desired configuration.
+func planActions(status *NodeStatus, desiredVersion string, desiredConfig []byte, currentConfig []byte) []Action {
+	var actions []Action
+
+	if !status.Installed {
+		actions = append(actions, ActionInstall)
+	} else if status.Version != desiredVersion {
+		actions = append(actions, ActionUpgrade)
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(desiredConfig), bytes.TrimSpace(currentConfig)) {
+		actions = append(actions, ActionConfigure)
+	}
+
+	return actions
+}
+
+// Exec runs a command on all nodes that match the selector. The output
+// of each node is written to the writer, prefixed with the node's name.
+func (e *Engine) Exec(selector Role, command string, output io.Writer) error {
+	for _, node := range e.selectNodes(selector) {
+		buffer := new(bytes.Buffer)
+
+		node.Logger.Info().Str("command", command).Msg("Running command")
+		err := node.Do(sshx.Cmd{
+			Cmd:    command,
+			Stdout: buffer,
+			Stderr: buffer,
+		})
+
+		scanner := bufio.NewScanner(buffer)
+		for scanner.Scan() {
+			if _, err := fmt.Fprintf(output, "%s: %s\n", node.ID(), scanner.Text()); err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("command failed on %s: %w", node.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+// ResolveVersion resolves a release channel to the k3s version
+// that the channel currently points to. Versions are returned as is.
+func ResolveVersion(version string) (string, error) {
+	return resolveVersion(ChannelURL, version)
+}
+
+// resolveVersion resolves a release channel via the channel server.
+func resolveVersion(channelURL string, version string) (string, error) {
+	isChannel := false
+	for _, channel := range Channels {
+		if channel == version {
+			isChannel = true
+			break
+		}
+	}
+	if !isChannel {
+		return version, nil
+	}
+
+	// The channel server redirects to the release of the channel.
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(channelURL + "/" + version)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("failed to resolve release channel: " + version)
+	}
+
+	return path.Base(location), nil
+}
+
+// nodeStatus fetches the observed state of a single node.
+func (e *Engine) nodeStatus(node *Node) (*NodeStatus, error) {
+	status := &NodeStatus{
+		Name: node.ID(),
+		Host: node.SSH.Host,
+		Role: node.Role,
+	}
+
+	versionBuffer := new(bytes.Buffer)
+	if err := node.Do(sshx.Cmd{
+		Cmd:    "k3s --version 2>/dev/null || true",
+		Stdout: versionBuffer,
+	}); err != nil {
+		return nil, err
+	}
+
+	// The output looks like this: "k3s version v1.30.5+k3s1 (9b586704)".
+	fields := strings.Fields(versionBuffer.String())
+	if len(fields) >= 3 && fields[1] == "version" {
+		status.Installed = true
+		status.Version = fields[2]
+	}
+
+	service := "k3s"
+	if node.Role == RoleAgent {
+		service = "k3s-agent"
+	}
+
+	activeBuffer := new(bytes.Buffer)
+	if err := node.Do(sshx.Cmd{
+		Cmd:    "systemctl is-active " + service + " 2>/dev/null || true",
+		Stdout: activeBuffer,
+	}); err != nil {
+		return nil, err
+	}
+	status.Active = strings.TrimSpace(activeBuffer.String()) == "active"
+
+	return status, nil
+}
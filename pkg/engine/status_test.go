@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestResolveVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable":
+			http.Redirect(w, r, "https://github.com/k3s-io/k3s/releases/tag/v1.30.5+k3s1", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"stable", "v1.30.5+k3s1", false},
+		{"latest", "", true},
+		{"v1.29.1+k3s2", "v1.29.1+k3s2", false},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveVersion(server.URL, tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveVersion(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveVersion(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestPlanActions(t *testing.T) {
+	config := []byte("node-name: kube1\n")
+
+	tests := []struct {
+		name          string
+		status        NodeStatus
+		currentConfig []byte
+		want          []Action
+	}{
+		{"not installed", NodeStatus{}, nil, []Action{ActionInstall, ActionConfigure}},
+		{"up to date", NodeStatus{Installed: true, Version: "v1.30.5+k3s1"}, []byte("node-name: kube1"), nil},
+		{"outdated", NodeStatus{Installed: true, Version: "v1.29.1+k3s2"}, config, []Action{ActionUpgrade}},
+		{"config changed", NodeStatus{Installed: true, Version: "v1.30.5+k3s1"}, []byte("node-name: kube2\n"), []Action{ActionConfigure}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planActions(&tt.status, "v1.30.5+k3s1", config, tt.currentConfig); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planActions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
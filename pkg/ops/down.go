@@ -1,9 +1,10 @@
 package ops
 
 import (
-	"github.com/nicklasfrahm/k3se/pkg/engine"
+	"errors"
 )
 
+// Down destroys the cluster by uninstalling k3s from all nodes.
 func Down(options ...Option) error {
 	// Fetch the options for this operation.
 	opts, err := GetDefaultOptions().Apply(options...)
@@ -11,31 +12,22 @@ func Down(options ...Option) error {
 		return err
 	}
 
-	config, err := engine.LoadConfig(opts.ConfigPath)
-	if err != nil {
-		return err
+	// Reject a selection before any remote work, such as hooks, runs.
+	if len(opts.Nodes) > 0 {
+		return errors.New("node selection is not supported for uninstallations")
 	}
 
-	eng, err := engine.New(engine.WithLogger(opts.Logger))
+	eng, err := connect(opts)
 	if err != nil {
 		return err
 	}
 
-	if err := eng.SetSpec(config); err != nil {
-		return err
-	}
-
-	if err := eng.Connect(); err != nil {
-		return err
-	}
+	// Ensure that connections are closed if the operation fails.
+	defer eng.Disconnect()
 
 	if err := eng.Uninstall(); err != nil {
 		return err
 	}
 
-	if err := eng.Disconnect(); err != nil {
-		return err
-	}
-
-	return nil
+	return eng.Disconnect()
 }
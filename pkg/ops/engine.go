@@ -0,0 +1,45 @@
+package ops
+
+import (
+	"github.com/nicklasfrahm/k3se/pkg/engine"
+)
+
+// connect loads the configuration, creates a new engine and connects
+// to all nodes. If connecting fails, all connections that were already
+// established are closed. Otherwise the caller is responsible for
+// disconnecting the engine.
+func connect(opts *Options) (*engine.Engine, error) {
+	config, err := engine.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Allow the version to be overridden, for example during an upgrade.
+	if opts.Version != "" {
+		config.Version = opts.Version
+	}
+
+	eng, err := engine.New(
+		engine.WithLogger(opts.Logger),
+		engine.WithSecure(opts.Secure),
+		engine.WithChaos(opts.Chaos),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := eng.SetSpec(config); err != nil {
+		return nil, err
+	}
+
+	if err := eng.Select(opts.Nodes...); err != nil {
+		return nil, err
+	}
+
+	if err := eng.Connect(); err != nil {
+		eng.Disconnect()
+		return nil, err
+	}
+
+	return eng, nil
+}
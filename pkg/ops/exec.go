@@ -0,0 +1,33 @@
+package ops
+
+import (
+	"errors"
+)
+
+// Exec runs the command specified via WithCommand on all nodes
+// matching the selector and writes the output to the configured output.
+func Exec(options ...Option) error {
+	// Fetch the options for this operation.
+	opts, err := GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return err
+	}
+
+	if opts.Command == "" {
+		return errors.New("no command specified")
+	}
+
+	eng, err := connect(opts)
+	if err != nil {
+		return err
+	}
+
+	// Ensure that connections are closed if the operation fails.
+	defer eng.Disconnect()
+
+	if err := eng.Exec(opts.Selector, opts.Command, opts.Output); err != nil {
+		return err
+	}
+
+	return eng.Disconnect()
+}
@@ -1,9 +1,7 @@
 package ops
 
-import (
-	"github.com/nicklasfrahm/k3se/pkg/engine"
-)
-
+// KubeConfig downloads the kubeconfig of the cluster and merges
+// it into the kubeconfig at the configured location.
 // TODO: Reduce amount of network traffic. The current setup is
 //       not optimal as it will connect to all nodes despite only
 //       needing to download the config from a single node.
@@ -14,31 +12,17 @@ func KubeConfig(options ...Option) error {
 		return err
 	}
 
-	config, err := engine.LoadConfig(opts.ConfigPath)
+	eng, err := connect(opts)
 	if err != nil {
 		return err
 	}
 
-	eng, err := engine.New(engine.WithLogger(opts.Logger))
-	if err != nil {
-		return err
-	}
-
-	if err := eng.SetSpec(config); err != nil {
-		return err
-	}
-
-	if err := eng.Connect(); err != nil {
-		return err
-	}
+	// Ensure that connections are closed if the operation fails.
+	defer eng.Disconnect()
 
 	if err := eng.KubeConfig(opts.KubeConfigPath); err != nil {
 		return err
 	}
 
-	if err := eng.Disconnect(); err != nil {
-		return err
-	}
-
-	return nil
+	return eng.Disconnect()
 }
@@ -1,6 +1,14 @@
 package ops
 
-import "github.com/rs/zerolog"
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"github.com/nicklasfrahm/k3se/pkg/engine"
+)
 
 const (
 	// Program is used to configure the name of the configuration file.
@@ -14,6 +22,13 @@ type Options struct {
 	ConfigPath     string
 	KubeConfigPath string
 	Logger         *zerolog.Logger
+	Secure         bool
+	Version        string
+	Command        string
+	Selector       engine.Role
+	Nodes          []string
+	Chaos          *engine.Chaos
+	Output         io.Writer
 }
 
 // Option applies a configuration option
@@ -33,10 +48,14 @@ func (o *Options) Apply(options ...Option) (*Options, error) {
 // GetDefaultOptions returns the default options
 // for all operations of this library.
 func GetDefaultOptions() *Options {
+	logger := zerolog.Nop()
 
 	return &Options{
 		ConfigPath:     Program + ".yml",
 		KubeConfigPath: DefaultKubeConfigPath,
+		Logger:         &logger,
+		Selector:       engine.RoleAny,
+		Output:         os.Stdout,
 	}
 }
 
@@ -63,3 +82,65 @@ func WithKubeConfigPath(kubeConfigPath string) Option {
 		return nil
 	}
 }
+
+// WithSecure enables the hardened mode. For more information,
+// please refer to the documentation of engine.WithSecure.
+func WithSecure(secure bool) Option {
+	return func(options *Options) error {
+		options.Secure = secure
+		return nil
+	}
+}
+
+// WithVersion overrides the version of k3s specified in the configuration.
+func WithVersion(version string) Option {
+	return func(options *Options) error {
+		options.Version = version
+		return nil
+	}
+}
+
+// WithCommand sets the command to be executed on the nodes.
+func WithCommand(command string) Option {
+	return func(options *Options) error {
+		options.Command = command
+		return nil
+	}
+}
+
+// WithSelector restricts the operation to the nodes matching the role.
+func WithSelector(selector engine.Role) Option {
+	return func(options *Options) error {
+		if selector != engine.RoleAny && selector != engine.RoleServer && selector != engine.RoleAgent {
+			return fmt.Errorf("unsupported role: %s", selector)
+		}
+
+		options.Selector = selector
+		return nil
+	}
+}
+
+// WithOutput overrides the writer that the output of commands is written to.
+func WithOutput(output io.Writer) Option {
+	return func(options *Options) error {
+		options.Output = output
+		return nil
+	}
+}
+
+// WithNodes restricts the operation to the nodes with the specified names.
+func WithNodes(names ...string) Option {
+	return func(options *Options) error {
+		options.Nodes = names
+		return nil
+	}
+}
+
+// WithChaos injects faults into the operations on nodes. For more
+// information, please refer to the documentation of engine.WithChaos.
+func WithChaos(chaos *engine.Chaos) Option {
+	return func(options *Options) error {
+		options.Chaos = chaos
+		return nil
+	}
+}
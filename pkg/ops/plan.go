@@ -0,0 +1,34 @@
+package ops
+
+import (
+	"github.com/nicklasfrahm/k3se/pkg/engine"
+)
+
+// Plan computes the changes that would be performed on each
+// node when bringing the cluster up without applying them.
+func Plan(options ...Option) (*engine.ClusterPlan, error) {
+	// Fetch the options for this operation.
+	opts, err := GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	eng, err := connect(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure that connections are closed if the operation fails.
+	defer eng.Disconnect()
+
+	steps, err := eng.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := eng.Disconnect(); err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,33 @@
+package ops
+
+import (
+	"github.com/nicklasfrahm/k3se/pkg/engine"
+)
+
+// Status fetches the observed state of all nodes and addons in the cluster.
+func Status(options ...Option) (*engine.ClusterStatus, error) {
+	// Fetch the options for this operation.
+	opts, err := GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	eng, err := connect(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure that connections are closed if the operation fails.
+	defer eng.Disconnect()
+
+	statuses, err := eng.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := eng.Disconnect(); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
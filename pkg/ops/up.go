@@ -1,9 +1,12 @@
 package ops
 
 import (
+	"errors"
+
 	"github.com/nicklasfrahm/k3se/pkg/engine"
 )
 
+// Up deploys a new cluster or upgrades an existing one.
 func Up(options ...Option) error {
 	// Fetch the options for this operation.
 	opts, err := GetDefaultOptions().Apply(options...)
@@ -11,34 +14,33 @@ func Up(options ...Option) error {
 		return err
 	}
 
-	config, err := engine.LoadConfig(opts.ConfigPath)
-	if err != nil {
-		return err
+	// Reject a selection before any remote work, such as hooks, runs.
+	if len(opts.Nodes) > 0 {
+		return errors.New("node selection is not supported for installations")
 	}
 
-	eng, err := engine.New(engine.WithLogger(opts.Logger))
+	eng, err := connect(opts)
 	if err != nil {
 		return err
 	}
 
-	if err := eng.SetSpec(config); err != nil {
+	// Ensure that connections are closed if the operation fails.
+	defer eng.Disconnect()
+
+	if err := eng.RunHooks(engine.StagePre); err != nil {
 		return err
 	}
 
-	if err := eng.Connect(); err != nil {
+	if err := eng.Install(); err != nil {
 		return err
 	}
 
-	if err := eng.Install(); err != nil {
+	if err := eng.RunHooks(engine.StagePost); err != nil {
 		return err
 	}
 
 	// TODO: Store state on server nodes to allow for configuration diffing later on.
 	// TODO: Fetch state from Git history.
 
-	if err := eng.Disconnect(); err != nil {
-		return err
-	}
-
-	return nil
+	return eng.Disconnect()
 }
@@ -0,0 +1,22 @@
+package ops
+
+import (
+	"errors"
+)
+
+// Upgrade changes the version of k3s on all nodes to the version
+// specified via WithVersion, regardless of the configured version.
+// Servers are upgraded one after another, followed by the agents.
+func Upgrade(options ...Option) error {
+	// Fetch the options for this operation.
+	opts, err := GetDefaultOptions().Apply(options...)
+	if err != nil {
+		return err
+	}
+
+	if opts.Version == "" {
+		return errors.New("no version specified")
+	}
+
+	return Up(options...)
+}
@@ -1,16 +1,30 @@
 package sshx
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/user"
+	"strings"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
+var (
+	// ErrTTYRequired is returned if sudo on the remote host refuses to run
+	// a command because it requires a TTY and the command can not be retried
+	// with a pseudo-terminal allocated.
+	ErrTTYRequired = errors.New("sudo requires a TTY: please add \"Defaults !requiretty\" to the sudoers configuration of the remote host")
+
+	// ErrPasswordRequired is returned if sudo on the remote host
+	// refuses to run a command because it requires a password.
+	ErrPasswordRequired = errors.New("sudo requires a password: please configure passwordless sudo via \"NOPASSWD\" for the remote user")
+)
+
 // Config is a flat configuration for an SSH connection.
 type Config struct {
 	Host              string   `yaml:"host"`
@@ -134,6 +148,10 @@ func (client *Client) normalizeConfig(config *Config) (*ssh.ClientConfig, error)
 			authMethod = ssh.PublicKeys(signer)
 		}
 	} else if config.Password != "" {
+		if client.PasswordAuthDisabled {
+			return nil, errors.New("password authentication is disabled: please use public key authentication")
+		}
+
 		// Fall back to password authentication.
 		authMethod = ssh.Password(config.Password)
 		client.Logger.Warn().Msg("Using password authentication is insecure!")
@@ -153,6 +171,10 @@ func (client *Client) normalizeConfig(config *Config) (*ssh.ClientConfig, error)
 			return nil
 		}
 	} else {
+		if client.StrictHostKey {
+			return nil, fmt.Errorf("host key verification is enforced: no fingerprint specified for host: %s", config.Host)
+		}
+
 		client.Logger.Warn().Msg("Skipping host key verification is insecure!")
 		client.Logger.Warn().Msg("This allows for person-in-the-middle attacks!")
 		client.Logger.Warn().Msg("Please consider using fingerprint verification!")
@@ -175,8 +197,49 @@ func (client *Client) normalizeConfig(config *Config) (*ssh.ClientConfig, error)
 	}, nil
 }
 
-// Do executes a command on the remote host.
+// Do executes a command on the remote host. If the command fails
+// because sudo is configured to require a TTY, the command will be
+// retried with a pseudo-terminal allocated.
 func (client *Client) Do(command Cmd) error {
+	output := new(bytes.Buffer)
+
+	err := client.run(command, output, false)
+	if err == nil {
+		return nil
+	}
+
+	if requiresPassword(output.String()) {
+		return fmt.Errorf("%w: %v", ErrPasswordRequired, err)
+	}
+
+	if !requiresTTY(output.String()) {
+		return err
+	}
+
+	// The input was already consumed by the first attempt.
+	if command.Stdin != nil {
+		return fmt.Errorf("%w: %v", ErrTTYRequired, err)
+	}
+
+	client.Logger.Warn().Msg("Retrying command with pseudo-terminal as sudo requires a TTY")
+	client.Logger.Warn().Msg("Please consider disabling \"requiretty\" in the sudoers configuration!")
+
+	output.Reset()
+	if err := client.run(command, output, true); err != nil {
+		if requiresTTY(output.String()) {
+			return fmt.Errorf("%w: %v", ErrTTYRequired, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// run executes a command on the remote host and captures the
+// standard error of the command in the provided buffer.
+// If a pseudo-terminal is requested, standard output is
+// captured as well, because the PTY merges both streams.
+func (client *Client) run(command Cmd, output *bytes.Buffer, pty bool) error {
 	session, err := client.SSH.NewSession()
 	if err != nil {
 		return err
@@ -186,12 +249,48 @@ func (client *Client) Do(command Cmd) error {
 	// Set the command to execute.
 	session.Stdin = command.Stdin
 	session.Stdout = command.Stdout
-	session.Stderr = command.Stderr
+	session.Stderr = output
+	if command.Stderr != nil {
+		session.Stderr = io.MultiWriter(command.Stderr, output)
+	}
+
+	if pty {
+		// Disable echo to prevent the input from showing up in the output
+		// and keep line endings unchanged, which would otherwise be CRLF.
+		modes := ssh.TerminalModes{
+			ssh.ECHO:  0,
+			ssh.ONLCR: 0,
+		}
+		if err := session.RequestPty("xterm", 80, 40, modes); err != nil {
+			return err
+		}
+
+		// The pseudo-terminal merges standard error into standard output,
+		// which is why we also need to capture it to detect sudo failures.
+		if command.Stdout != nil {
+			session.Stdout = io.MultiWriter(command.Stdout, output)
+		} else {
+			session.Stdout = output
+		}
+	}
 
 	// Execute the command.
 	return session.Run(command.String())
 }
 
+// requiresTTY checks if the output of a command indicates that
+// sudo refused to run because "requiretty" is configured.
+func requiresTTY(output string) bool {
+	return strings.Contains(output, "sorry, you must have a tty to run sudo")
+}
+
+// requiresPassword checks if the output of a command indicates that
+// sudo refused to run because it wants to prompt for a password.
+func requiresPassword(output string) bool {
+	return strings.Contains(output, "sudo: a terminal is required to read the password") ||
+		strings.Contains(output, "sudo: a password is required")
+}
+
 // Close closes the SFTP connection first as it
 // piggy-backs on the SSH connection. After that
 // the SSH connection of the client is closed.
@@ -0,0 +1,69 @@
+package sshx
+
+import "testing"
+
+func TestRequiresTTY(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"sudo: sorry, you must have a tty to run sudo\n", true},
+		{"sudo: a terminal is required to read the password; either use the -S option to read from standard input or configure an askpass helper\n", false},
+		{"sudo: a password is required\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := requiresTTY(tt.output); got != tt.want {
+			t.Errorf("requiresTTY(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestRequiresPassword(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"sudo: a terminal is required to read the password; either use the -S option to read from standard input or configure an askpass helper\n", true},
+		{"sudo: a password is required\n", true},
+		{"sudo: sorry, you must have a tty to run sudo\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := requiresPassword(tt.output); got != tt.want {
+			t.Errorf("requiresPassword(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeConfigSecureOptions(t *testing.T) {
+	fingerprint := "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+
+	tests := []struct {
+		name    string
+		options []Option
+		config  Config
+		wantErr bool
+	}{
+		{"insecure defaults", nil, Config{Host: "10.0.0.1", Password: "secret"}, false},
+		{"password auth disabled", []Option{WithPasswordAuthDisabled()}, Config{Host: "10.0.0.1", Password: "secret", Fingerprint: fingerprint}, true},
+		{"strict host key without fingerprint", []Option{WithStrictHostKey()}, Config{Host: "10.0.0.1", Password: "secret"}, true},
+		{"strict host key with fingerprint", []Option{WithStrictHostKey()}, Config{Host: "10.0.0.1", Password: "secret", Fingerprint: fingerprint}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := GetDefaultOptions().Apply(tt.options...)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			client := &Client{Options: opts}
+			if _, err := client.normalizeConfig(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("normalizeConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
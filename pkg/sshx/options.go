@@ -12,6 +12,9 @@ type Options struct {
 	Proxy        *Client
 	Timeout      time.Duration
 	STFPDisabled bool
+
+	StrictHostKey        bool
+	PasswordAuthDisabled bool
 }
 
 // Option applies a configuration option
@@ -73,3 +76,21 @@ func WithSTFPDisabled() Option {
 		return nil
 	}
 }
+
+// WithStrictHostKey enforces host key verification
+// and refuses to connect if no fingerprint is set.
+func WithStrictHostKey() Option {
+	return func(options *Options) error {
+		options.StrictHostKey = true
+		return nil
+	}
+}
+
+// WithPasswordAuthDisabled refuses to connect
+// using password authentication.
+func WithPasswordAuthDisabled() Option {
+	return func(options *Options) error {
+		options.PasswordAuthDisabled = true
+		return nil
+	}
+}